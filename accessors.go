@@ -0,0 +1,282 @@
+package bencode
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ErrKeyMissing is returned by the typed accessors of `Dictionary` and `List`
+// when the key, or the list index, doesn't exist.
+type ErrKeyMissing struct {
+	// Key is the dictionary key, or the list index in decimal.
+	Key string
+}
+
+func (e *ErrKeyMissing) Error() string {
+	return fmt.Sprintf("missing key %q", e.Key)
+}
+
+// ErrWrongType is returned by the typed accessors of `Dictionary` and `List`
+// when the value has a different kind than requested.
+type ErrWrongType struct {
+	// Key is the dictionary key, or the list index in decimal.
+	Key  string
+	Want Kind
+	Got  Kind
+}
+
+func (e *ErrWrongType) Error() string {
+	return fmt.Sprintf("value of key %q is %s, not %s", e.Key, e.Got, e.Want)
+}
+
+// ErrIntegerOutOfRange is returned by the `GetInteger` accessors of
+// `Dictionary` and `List` when the value is a `BigInteger` that doesn't fit in
+// an `Integer`.
+type ErrIntegerOutOfRange struct {
+	// Key is the dictionary key, or the list index in decimal.
+	Key string
+}
+
+func (e *ErrIntegerOutOfRange) Error() string {
+	return fmt.Sprintf("value of key %q is an integer that doesn't fit in an int64", e.Key)
+}
+
+var (
+	_ error = (*ErrKeyMissing)(nil)
+	_ error = (*ErrWrongType)(nil)
+	_ error = (*ErrIntegerOutOfRange)(nil)
+)
+
+// kindOfValue returns the kind of `v`.
+func kindOfValue(v Value) Kind {
+	switch v.(type) {
+	case Integer, BigInteger:
+		return KindInteger
+	case String:
+		return KindString
+	case List:
+		return KindList
+	case *Dictionary:
+		return KindDictionary
+	default:
+		return KindInvalid
+	}
+}
+
+// valueOfKind returns `v` if it exists and has the kind `want`.
+func valueOfKind(key string, v Value, ok bool, want Kind) (Value, error) {
+	if !ok {
+		return nil, &ErrKeyMissing{
+			Key: key,
+		}
+	}
+
+	if got := kindOfValue(v); got != want {
+		return nil, &ErrWrongType{
+			Key:  key,
+			Want: want,
+			Got:  got,
+		}
+	}
+
+	return v, nil
+}
+
+func asString(key string, v Value, ok bool) (String, error) {
+	v, err := valueOfKind(key, v, ok, KindString)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(String), nil
+}
+
+// asInteger also accepts a `BigInteger` that fits in an `Integer`.
+func asInteger(key string, v Value, ok bool) (Integer, error) {
+	v, err := valueOfKind(key, v, ok, KindInteger)
+	if err != nil {
+		return 0, err
+	}
+
+	n, isBig := v.(BigInteger)
+	if !isBig {
+		return v.(Integer), nil
+	}
+
+	if n.Int == nil {
+		return 0, nil
+	}
+
+	if !n.IsInt64() {
+		return 0, &ErrIntegerOutOfRange{
+			Key: key,
+		}
+	}
+
+	return Integer(n.Int64()), nil
+}
+
+func asList(key string, v Value, ok bool) (List, error) {
+	v, err := valueOfKind(key, v, ok, KindList)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(List), nil
+}
+
+func asDictionary(key string, v Value, ok bool) (*Dictionary, error) {
+	v, err := valueOfKind(key, v, ok, KindDictionary)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Dictionary), nil
+}
+
+// GetString returns the string at `key`, or an `ErrKeyMissing` or
+// `ErrWrongType` error.
+func (d *Dictionary) GetString(key string) (String, error) {
+	v, ok := d.Get(String(key))
+
+	return asString(key, v, ok)
+}
+
+// GetInteger returns the integer at `key`, or an `ErrKeyMissing`,
+// `ErrWrongType` or `ErrIntegerOutOfRange` error.
+func (d *Dictionary) GetInteger(key string) (Integer, error) {
+	v, ok := d.Get(String(key))
+
+	return asInteger(key, v, ok)
+}
+
+// GetList returns the list at `key`, or an `ErrKeyMissing` or `ErrWrongType`
+// error.
+func (d *Dictionary) GetList(key string) (List, error) {
+	v, ok := d.Get(String(key))
+
+	return asList(key, v, ok)
+}
+
+// GetDictionary returns the dictionary at `key`, or an `ErrKeyMissing` or
+// `ErrWrongType` error.
+func (d *Dictionary) GetDictionary(key string) (*Dictionary, error) {
+	v, ok := d.Get(String(key))
+
+	return asDictionary(key, v, ok)
+}
+
+// MustGetString is like `GetString`, but panics on error.
+func (d *Dictionary) MustGetString(key string) String {
+	s, err := d.GetString(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// MustGetInteger is like `GetInteger`, but panics on error.
+func (d *Dictionary) MustGetInteger(key string) Integer {
+	n, err := d.GetInteger(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+// MustGetList is like `GetList`, but panics on error.
+func (d *Dictionary) MustGetList(key string) List {
+	l, err := d.GetList(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return l
+}
+
+// MustGetDictionary is like `GetDictionary`, but panics on error.
+func (d *Dictionary) MustGetDictionary(key string) *Dictionary {
+	dict, err := d.GetDictionary(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return dict
+}
+
+// get returns the item at index `i`, and its key for errors.
+func (l List) get(i int) (string, Value, bool) {
+	key := strconv.Itoa(i)
+
+	if i < 0 || i >= len(l) {
+		return key, nil, false
+	}
+
+	return key, l[i], true
+}
+
+// GetString returns the string at index `i`, or an `ErrKeyMissing` or
+// `ErrWrongType` error.
+func (l List) GetString(i int) (String, error) {
+	return asString(l.get(i))
+}
+
+// GetInteger returns the integer at index `i`, or an `ErrKeyMissing`,
+// `ErrWrongType` or `ErrIntegerOutOfRange` error.
+func (l List) GetInteger(i int) (Integer, error) {
+	return asInteger(l.get(i))
+}
+
+// GetList returns the list at index `i`, or an `ErrKeyMissing` or
+// `ErrWrongType` error.
+func (l List) GetList(i int) (List, error) {
+	return asList(l.get(i))
+}
+
+// GetDictionary returns the dictionary at index `i`, or an `ErrKeyMissing` or
+// `ErrWrongType` error.
+func (l List) GetDictionary(i int) (*Dictionary, error) {
+	return asDictionary(l.get(i))
+}
+
+// MustGetString is like `GetString`, but panics on error.
+func (l List) MustGetString(i int) String {
+	s, err := l.GetString(i)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// MustGetInteger is like `GetInteger`, but panics on error.
+func (l List) MustGetInteger(i int) Integer {
+	n, err := l.GetInteger(i)
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+// MustGetList is like `GetList`, but panics on error.
+func (l List) MustGetList(i int) List {
+	list, err := l.GetList(i)
+	if err != nil {
+		panic(err)
+	}
+
+	return list
+}
+
+// MustGetDictionary is like `GetDictionary`, but panics on error.
+func (l List) MustGetDictionary(i int) *Dictionary {
+	dict, err := l.GetDictionary(i)
+	if err != nil {
+		panic(err)
+	}
+
+	return dict
+}
@@ -0,0 +1,109 @@
+package bencode_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+func TestDictionary_GetTyped(t *testing.T) {
+	huge, _ := new(big.Int).SetString("99999999999999999999", 10)
+
+	d := makeDictionary(map[string]bencode.Value{
+		"name":   bencode.String("x"),
+		"length": bencode.Integer(42),
+		"big":    bencode.BigInteger{big.NewInt(7)},
+		"huge":   bencode.BigInteger{huge},
+		"files":  bencode.List{bencode.String("a"), bencode.Integer(1)},
+		"info":   makeDictionary(map[string]bencode.Value{"private": bencode.Integer(1)}),
+	})
+
+	if got, err := d.GetString("name"); err != nil || string(got) != "x" {
+		t.Errorf("d.GetString(\"name\") = %#v, %#v; want \"x\", nil", got, err)
+	}
+	if got, err := d.GetInteger("length"); err != nil || got != 42 {
+		t.Errorf("d.GetInteger(\"length\") = %#v, %#v; want 42, nil", got, err)
+	}
+	if got, err := d.GetInteger("big"); err != nil || got != 7 {
+		t.Errorf("d.GetInteger(\"big\") = %#v, %#v; want 7, nil", got, err)
+	}
+	if got, err := d.GetList("files"); err != nil || len(got) != 2 {
+		t.Errorf("d.GetList(\"files\") = %#v, %#v; want 2 items, nil", got, err)
+	}
+	if got := d.MustGetDictionary("info").MustGetInteger("private"); got != 1 {
+		t.Errorf("d.MustGetDictionary(\"info\").MustGetInteger(\"private\") = %#v; want 1", got)
+	}
+
+	var missingErr *bencode.ErrKeyMissing
+	if _, err := d.GetString("comment"); !errors.As(err, &missingErr) || missingErr.Key != "comment" {
+		t.Errorf("d.GetString(\"comment\") returned %#v; want *bencode.ErrKeyMissing", err)
+	}
+
+	var rangeErr *bencode.ErrIntegerOutOfRange
+	if _, err := d.GetInteger("huge"); !errors.As(err, &rangeErr) || rangeErr.Key != "huge" {
+		t.Errorf("d.GetInteger(\"huge\") returned %#v; want *bencode.ErrIntegerOutOfRange", err)
+	}
+
+	testCases := []struct {
+		Get  func() error
+		Want bencode.Kind
+		Got  bencode.Kind
+	}{
+		{
+			Get:  func() error { _, err := d.GetString("length"); return err },
+			Want: bencode.KindString,
+			Got:  bencode.KindInteger,
+		},
+		{
+			Get:  func() error { _, err := d.GetDictionary("files"); return err },
+			Want: bencode.KindDictionary,
+			Got:  bencode.KindList,
+		},
+	}
+
+	for i, tc := range testCases {
+		err := tc.Get()
+
+		var wrongTypeErr *bencode.ErrWrongType
+		if !errors.As(err, &wrongTypeErr) {
+			t.Errorf("testCases[%d]: returned %#v; want *bencode.ErrWrongType", i, err)
+
+			continue
+		}
+
+		if wrongTypeErr.Want != tc.Want || wrongTypeErr.Got != tc.Got {
+			t.Errorf("testCases[%d]: Want, Got = %s, %s; want %s, %s", i, wrongTypeErr.Want, wrongTypeErr.Got, tc.Want, tc.Got)
+		}
+	}
+}
+
+func TestList_GetTyped(t *testing.T) {
+	l := bencode.List{bencode.String("a"), bencode.Integer(1)}
+
+	if got := l.MustGetString(0); string(got) != "a" {
+		t.Errorf("l.MustGetString(0) = %#v; want \"a\"", got)
+	}
+	if got := l.MustGetInteger(1); got != 1 {
+		t.Errorf("l.MustGetInteger(1) = %#v; want 1", got)
+	}
+
+	var missingErr *bencode.ErrKeyMissing
+	if _, err := l.GetList(2); !errors.As(err, &missingErr) || missingErr.Key != "2" {
+		t.Errorf("l.GetList(2) returned %#v; want *bencode.ErrKeyMissing", err)
+	}
+
+	var wrongTypeErr *bencode.ErrWrongType
+	if _, err := l.GetInteger(0); !errors.As(err, &wrongTypeErr) || wrongTypeErr.Key != "0" {
+		t.Errorf("l.GetInteger(0) returned %#v; want *bencode.ErrWrongType", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("l.MustGetDictionary(-1) didn't panic")
+		}
+	}()
+
+	l.MustGetDictionary(-1)
+}
@@ -0,0 +1,187 @@
+package bencode
+
+import (
+	"bytes"
+	"math/big"
+)
+
+// Clone returns a deep copy of `v`, which shares no memory with `v`.
+//
+// Values of types other than `Integer`, `BigInteger`, `String`, `List` and
+// `*Dictionary` are returned as they are.
+func Clone(v Value) Value {
+	switch v := v.(type) {
+	case BigInteger:
+		if v.Int == nil {
+			return v
+		}
+
+		return BigInteger{new(big.Int).Set(v.Int)}
+	case String:
+		if v == nil {
+			return v
+		}
+
+		return String(append([]byte{}, v...))
+	case List:
+		if v == nil {
+			return v
+		}
+
+		l := make(List, len(v))
+		for i, item := range v {
+			l[i] = Clone(item)
+		}
+
+		return l
+	case *Dictionary:
+		if v == nil {
+			return v
+		}
+
+		items := v.snapshot()
+		for i := range items {
+			items[i].Key = Clone(items[i].Key).(String)
+			items[i].Value = Clone(items[i].Value)
+		}
+
+		dict := NewDictionary()
+		dict.chunks = chunksOf(items)
+
+		return dict
+	default:
+		return v
+	}
+}
+
+// Equal reports whether `a` and `b` are the same bencode value. It's
+// equivalent to `Compare(a, b) == 0`.
+func Equal(a, b Value) bool {
+	return Compare(a, b) == 0
+}
+
+// Compare returns -1, 0 or 1 depending on whether `a` is less than, equal to,
+// or greater than `b`.
+//
+// Values of different kinds are ordered as integers, strings, lists and
+// dictionaries. Integers are compared by their value (so `Integer(1)` is equal
+// to a `BigInteger` of 1), strings by their bytes, and lists element by
+// element. Dictionaries are compared item by item, in key order, comparing
+// each key and then its value, and a nil `*Dictionary` is ordered before any
+// other dictionary. Values of other types, including nil, are ordered before
+// all others, and compared by their encoding.
+func Compare(a, b Value) int {
+	aKind := kindOfValue(a)
+	bKind := kindOfValue(b)
+
+	if aKind != bKind {
+		if aKind < bKind {
+			return -1
+		}
+
+		return 1
+	}
+
+	switch aKind {
+	case KindInteger:
+		return compareIntegers(a, b)
+	case KindString:
+		return bytes.Compare(a.(String), b.(String))
+	case KindList:
+		return compareLists(a.(List), b.(List))
+	case KindDictionary:
+		return compareDictionaries(a.(*Dictionary), b.(*Dictionary))
+	default:
+		return bytes.Compare(encodeOrNil(a), encodeOrNil(b))
+	}
+}
+
+// encodeOrNil returns the encoding of `v`, or nil if `v` is nil.
+func encodeOrNil(v Value) []byte {
+	if v == nil {
+		return nil
+	}
+
+	return v.Bencode()
+}
+
+func compareIntegers(a, b Value) int {
+	aInt, aOK := a.(Integer)
+	bInt, bOK := b.(Integer)
+
+	if aOK && bOK {
+		switch {
+		case aInt < bInt:
+			return -1
+		case aInt > bInt:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return bigIntOf(a).Cmp(bigIntOf(b))
+}
+
+// bigIntOf returns the value of an `Integer` or `BigInteger` as a `*big.Int`.
+func bigIntOf(v Value) *big.Int {
+	switch v := v.(type) {
+	case Integer:
+		return big.NewInt(int64(v))
+	case BigInteger:
+		if v.Int == nil {
+			return new(big.Int)
+		}
+
+		return v.Int
+	default:
+		return nil
+	}
+}
+
+func compareLists(a, b List) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareLengths(len(a), len(b))
+}
+
+func compareDictionaries(a, b *Dictionary) int {
+	switch {
+	case a == b:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	aItems := a.snapshot()
+	bItems := b.snapshot()
+
+	for i := 0; i < len(aItems) && i < len(bItems); i++ {
+		if c := bytes.Compare(aItems[i].Key, bItems[i].Key); c != 0 {
+			return c
+		}
+
+		if c := Compare(aItems[i].Value, bItems[i].Value); c != 0 {
+			return c
+		}
+	}
+
+	return compareLengths(len(aItems), len(bItems))
+}
+
+func compareLengths(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
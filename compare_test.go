@@ -0,0 +1,78 @@
+package bencode_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+func TestCompare(t *testing.T) {
+	huge, _ := new(big.Int).SetString("99999999999999999999", 10)
+
+	// Sorted in ascending order.
+	values := []bencode.Value{
+		nil,
+		bencode.Integer(-1),
+		bencode.Integer(0),
+		bencode.Integer(1),
+		bencode.BigInteger{huge},
+		bencode.String(""),
+		bencode.String("a"),
+		bencode.String("ab"),
+		bencode.String("b"),
+		bencode.List{},
+		bencode.List{bencode.Integer(1)},
+		bencode.List{bencode.Integer(1), bencode.Integer(2)},
+		bencode.List{bencode.String("a")},
+		(*bencode.Dictionary)(nil),
+		bencode.NewDictionary(),
+		makeDictionary(map[string]bencode.Value{"a": bencode.Integer(1)}),
+		makeDictionary(map[string]bencode.Value{"a": bencode.Integer(1), "b": bencode.Integer(0)}),
+		makeDictionary(map[string]bencode.Value{"a": bencode.Integer(2)}),
+		makeDictionary(map[string]bencode.Value{"b": bencode.Integer(0)}),
+	}
+
+	for i, a := range values {
+		for j, b := range values {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+
+			if got := bencode.Compare(a, b); got != want {
+				t.Errorf("Compare(values[%d], values[%d]) = %#v; want %#v", i, j, got, want)
+			}
+		}
+	}
+
+	if !bencode.Equal(bencode.Integer(7), bencode.BigInteger{big.NewInt(7)}) {
+		t.Errorf("Equal(Integer(7), BigInteger{7}) = false")
+	}
+}
+
+func TestClone(t *testing.T) {
+	inner := bencode.List{bencode.String("a"), bencode.Integer(1)}
+	original := makeDictionary(map[string]bencode.Value{
+		"list": inner,
+		"info": makeDictionary(map[string]bencode.Value{"name": bencode.String("x")}),
+	})
+
+	clone := bencode.Clone(original).(*bencode.Dictionary)
+
+	if !bencode.Equal(original, clone) {
+		t.Fatalf("Clone(original) is not equal to original")
+	}
+
+	// Modifying the original must not affect the clone.
+	inner[0].(bencode.String)[0] = 'z'
+	inner[1] = bencode.Integer(2)
+	original.MustGetDictionary("info").Set(bencode.String("name"), bencode.String("y"))
+	original.Remove(bencode.String("list"))
+
+	if got, want := string(clone.Bencode()), "d4:infod4:name1:xe4:listl1:ai1eee"; got != want {
+		t.Errorf("clone.Bencode() = %#v; want %#v", got, want)
+	}
+}
@@ -1,45 +1,286 @@
 package bencode
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
+	"reflect"
 	"strconv"
 )
 
 type ErrStringTooLong struct {
 	TokenOffset     int64
 	NextTokenOffset int64
+
+	// Path is the path of the string.
+	Path Path
 }
 
 func (e *ErrStringTooLong) Error() string {
-	return fmt.Sprintf("string too long at offset %d; next token starts at %d", e.TokenOffset, e.NextTokenOffset)
+	return fmt.Sprintf("string too long at offset %d%s; next token starts at %d", e.TokenOffset, inPath(e.Path), e.NextTokenOffset)
 }
 
 type ErrInvalidToken struct {
 	Offset int64
+
+	// Path is the path of the value that was being read.
+	Path Path
 }
 
 func (e *ErrInvalidToken) Error() string {
-	return fmt.Sprintf("unexpected byte at offset %d", e.Offset)
+	return fmt.Sprintf("unexpected byte at offset %d%s", e.Offset, inPath(e.Path))
 }
 
 type ErrUnexpectedByte struct {
 	Offset   int64
 	Got      byte
 	Expected byte
+
+	// Path is the path of the value that was being read.
+	Path Path
 }
 
 func (e *ErrUnexpectedByte) Error() string {
-	return fmt.Sprintf("unexpected byte %#v at offset %d, expected %#v", e.Got, e.Offset, e.Expected)
+	return fmt.Sprintf("unexpected byte %#v at offset %d%s, expected %#v", e.Got, e.Offset, inPath(e.Path), e.Expected)
+}
+
+// ErrDuplicateKey is returned in strict mode when a dictionary contains the
+// same key more than once.
+type ErrDuplicateKey struct {
+	Key            []byte
+	Offset         int64
+	PreviousOffset int64
+
+	// Path is the path of the dictionary that contains the key.
+	Path Path
+}
+
+func (e *ErrDuplicateKey) Error() string {
+	return fmt.Sprintf("duplicate dictionary key %q at offset %d%s; previously found at offset %d", e.Key, e.Offset, inPath(e.Path), e.PreviousOffset)
+}
+
+// ErrUnsortedKey is returned in strict mode when a dictionary key is not
+// greater than the previous key, comparing their raw bytes.
+type ErrUnsortedKey struct {
+	Key            []byte
+	Offset         int64
+	PreviousKey    []byte
+	PreviousOffset int64
+
+	// Path is the path of the dictionary that contains the key.
+	Path Path
+}
+
+func (e *ErrUnsortedKey) Error() string {
+	return fmt.Sprintf("dictionary key %q at offset %d%s is not sorted after key %q at offset %d", e.Key, e.Offset, inPath(e.Path), e.PreviousKey, e.PreviousOffset)
+}
+
+// ErrNonStringKey is returned when a dictionary key is not a string.
+type ErrNonStringKey struct {
+	// Raw holds the raw bytes of the token found in place of the key.
+	Raw              []byte
+	Offset           int64
+	DictionaryOffset int64
+
+	// Path is the path of the dictionary that contains the key.
+	Path Path
+}
+
+func (e *ErrNonStringKey) Error() string {
+	return fmt.Sprintf("non-string dictionary key %q at offset %d%s, in dictionary starting at offset %d", e.Raw, e.Offset, inPath(e.Path), e.DictionaryOffset)
+}
+
+// ErrUnexpectedEnd is returned when a dictionary or list ends where a value
+// was expected. That is, when a dictionary is closed right after a key, when
+// there is an end token outside of any dictionary or list, or when the input
+// ends before all dictionaries and lists are closed.
+type ErrUnexpectedEnd struct {
+	Offset int64
+
+	// ContainerOffset is the offset of the dictionary or list that was being
+	// read, or -1 if there was none.
+	ContainerOffset int64
+
+	// Key is the dictionary key that is missing its value, if any.
+	Key       []byte
+	KeyOffset int64
+
+	// Path is the path of the dictionary or list that was being read.
+	Path Path
+
+	// Err is `io.ErrUnexpectedEOF` if the input ended, or nil otherwise.
+	Err error
+}
+
+func (e *ErrUnexpectedEnd) Error() string {
+	what := "unexpected end"
+	if e.Err != nil {
+		what = "unexpected end of input"
+	}
+
+	if e.ContainerOffset < 0 {
+		return fmt.Sprintf("%s at offset %d", what, e.Offset)
+	}
+
+	if e.Key != nil {
+		return fmt.Sprintf("%s at offset %d%s; missing value for key %q at offset %d", what, e.Offset, inPath(e.Path), e.Key, e.KeyOffset)
+	}
+
+	return fmt.Sprintf("%s at offset %d%s, in container starting at offset %d", what, e.Offset, inPath(e.Path), e.ContainerOffset)
+}
+
+func (e *ErrUnexpectedEnd) Unwrap() error {
+	return e.Err
+}
+
+// ErrMaxDepthExceeded is returned when dictionaries and lists are nested
+// deeper than `DecoderOptions.MaxDepth`.
+type ErrMaxDepthExceeded struct {
+	Offset   int64
+	MaxDepth int
+
+	// Path is the path of the dictionary or list that exceeds the depth.
+	Path Path
+}
+
+func (e *ErrMaxDepthExceeded) Error() string {
+	return fmt.Sprintf("maximum nesting depth of %d exceeded at offset %d%s", e.MaxDepth, e.Offset, inPath(e.Path))
+}
+
+// ErrBudgetExceeded is returned when decoding a top-level value would exceed
+// one of the budgets in `DecoderOptions`.
+type ErrBudgetExceeded struct {
+	Offset int64
+
+	// Budget is the name of the `DecoderOptions` field that was exceeded.
+	Budget string
+	Limit  int64
+
+	// Path is the path of the value that exceeds the budget.
+	Path Path
+}
+
+func (e *ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf("%s of %d exceeded at offset %d%s", e.Budget, e.Limit, e.Offset, inPath(e.Path))
+}
+
+// ErrTrailingData is returned in strict mode, and by `Unmarshal`, when there
+// is more data after the top-level value.
+type ErrTrailingData struct {
+	Offset int64
+}
+
+func (e *ErrTrailingData) Error() string {
+	return fmt.Sprintf("unexpected data after top-level value at offset %d", e.Offset)
+}
+
+// ErrLeadingZero is returned when an integer, or the length prefix of a
+// string, has leading zeros, like `i03e` or `03:abc`.
+type ErrLeadingZero struct {
+	Offset int64
+
+	// Path is the path of the value that was being read.
+	Path Path
+}
+
+func (e *ErrLeadingZero) Error() string {
+	return fmt.Sprintf("number with leading zeros at offset %d%s", e.Offset, inPath(e.Path))
+}
+
+// ErrNegativeZero is returned when an integer is negative zero, like `i-0e`.
+type ErrNegativeZero struct {
+	Offset int64
+
+	// Path is the path of the value that was being read.
+	Path Path
+}
+
+func (e *ErrNegativeZero) Error() string {
+	return fmt.Sprintf("negative zero at offset %d%s", e.Offset, inPath(e.Path))
+}
+
+// ErrEmptyInteger is returned when an integer has no digits, like `ie` or
+// `i-e`.
+type ErrEmptyInteger struct {
+	Offset int64
+
+	// Path is the path of the value that was being read.
+	Path Path
+}
+
+func (e *ErrEmptyInteger) Error() string {
+	return fmt.Sprintf("integer without digits at offset %d%s", e.Offset, inPath(e.Path))
+}
+
+// SyntaxError is returned when the input is not valid bencode.
+type SyntaxError struct {
+	// Offset is the offset of the first offending byte.
+	Offset int64
+
+	// Got holds the offending bytes, truncated to their first 32 bytes. It's
+	// empty if the input ended in the middle of a token.
+	Got []byte
+
+	// Expected describes what was expected instead of `Got`.
+	Expected string
+
+	// Excerpt is a hex and ASCII dump of the input around `Offset`.
+	Excerpt string
+
+	// Path is the path of the value that was being read.
+	Path Path
+
+	// Err is the underlying error: `*ErrInvalidToken`, `*ErrUnexpectedByte`,
+	// `*ErrLeadingZero`, `*ErrNegativeZero`, `*ErrEmptyInteger`, a
+	// `*strconv.NumError` for numbers that don't fit in an int64, or
+	// `io.ErrUnexpectedEOF`.
+	Err error
+
+	isGotTruncated bool
 }
 
-var _ error = (*ErrUnexpectedByte)(nil)
+func (e *SyntaxError) Error() string {
+	if len(e.Got) == 0 {
+		return fmt.Sprintf("syntax error at offset %d%s: unexpected end of input, expected %s", e.Offset, inPath(e.Path), e.Expected)
+	}
+
+	ellipsis := ""
+	if e.isGotTruncated {
+		ellipsis = "..."
+	}
+
+	return fmt.Sprintf("syntax error at offset %d%s: unexpected %q%s, expected %s", e.Offset, inPath(e.Path), e.Got, ellipsis, e.Expected)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	_ error = (*ErrLeadingZero)(nil)
+	_ error = (*ErrNegativeZero)(nil)
+	_ error = (*ErrEmptyInteger)(nil)
+	_ error = (*SyntaxError)(nil)
+	_ error = (*ErrUnexpectedByte)(nil)
+	_ error = (*ErrDuplicateKey)(nil)
+	_ error = (*ErrUnsortedKey)(nil)
+	_ error = (*ErrNonStringKey)(nil)
+	_ error = (*ErrUnexpectedEnd)(nil)
+	_ error = (*ErrMaxDepthExceeded)(nil)
+	_ error = (*ErrBudgetExceeded)(nil)
+	_ error = (*ErrTrailingData)(nil)
+)
 
 var (
 	_ Token = (*TokenDictionaryStart)(nil)
 	_ Token = (*TokenListStart)(nil)
 	_ Token = (*TokenString)(nil)
+	_ Token = (*TokenSkippedString)(nil)
+	_ Token = (*TokenStringReader)(nil)
 	_ Token = (*TokenInteger)(nil)
 	_ Token = (*TokenEnd)(nil)
 )
@@ -47,7 +288,8 @@ var (
 var _ TokenReader = (*Decoder)(nil)
 
 // Token is an interface holding one of the token types: TokenDictionaryStart,
-// TokenListStart, TokenString, TokenInteger, TokenEnd.
+// TokenListStart, TokenString, TokenSkippedString, TokenStringReader,
+// TokenInteger, TokenEnd.
 type Token interface {
 	Offset() int64
 	Raw() []byte
@@ -84,16 +326,6 @@ type TokenString struct {
 	Value  []byte
 }
 
-func (ts *TokenString) setValue(value []byte) {
-	prefix := []byte(fmt.Sprintf("%d", len(value)))
-	parts := [][]byte{
-		prefix,
-		value,
-	}
-	ts.raw = bytes.Join(parts, []byte(":"))
-	ts.Value = ts.raw[len(prefix)+1:]
-}
-
 func (ts *TokenString) Offset() int64 {
 	return ts.offset
 }
@@ -102,11 +334,115 @@ func (ts *TokenString) Raw() []byte {
 	return ts.raw
 }
 
+// TokenSkippedString is a string whose payload was discarded without reading
+// it into memory. `Raw` only returns its length prefix.
+type TokenSkippedString struct {
+	offset int64
+	raw    []byte
+	Length int64
+}
+
+func (ts *TokenSkippedString) Offset() int64 {
+	return ts.offset
+}
+
+func (ts *TokenSkippedString) Raw() []byte {
+	return ts.raw
+}
+
+// TokenStringReader is a string whose payload can be read from `Reader`,
+// which returns at most `Length` bytes. `Raw` only returns its length prefix.
+//
+// `Reader` is only valid until the next call to a method of the decoder. The
+// part of the payload that hasn't been read by then is discarded.
+type TokenStringReader struct {
+	offset int64
+	raw    []byte
+	Length int64
+	Reader io.Reader
+}
+
+func (ts *TokenStringReader) Offset() int64 {
+	return ts.offset
+}
+
+func (ts *TokenStringReader) Raw() []byte {
+	return ts.raw
+}
+
+// stringReader reads the payload of a `TokenStringReader` from the input of
+// the decoder.
+type stringReader struct {
+	d         *Decoder
+	remaining int64
+
+	// tokenOffset and length describe the string, for errors.
+	tokenOffset int64
+	length      int64
+}
+
+func (sr *stringReader) Read(p []byte) (int, error) {
+	d := sr.d
+	if d == nil {
+		return 0, errors.New("string reader used after the decoder moved on")
+	}
+
+	if sr.remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > sr.remaining {
+		p = p[:sr.remaining]
+	}
+
+	var (
+		n   int
+		err error
+	)
+
+	if d.r == nil {
+		// `newStringReader` already checked that the payload is complete.
+		n = copy(p, d.data[d.offset:])
+	} else {
+		n, err = d.r.Read(p)
+		d.recent.Write(p[:n])
+	}
+
+	d.offset += int64(n)
+	sr.remaining -= int64(n)
+
+	if err == io.EOF && sr.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
 type TokenInteger struct {
 	baseToken
 	Value int64
+
+	// Big holds the value of integers that don't fit in an int64, or of all
+	// integers, depending on `DecoderOptions.BigIntegers`. `Value` is zero if
+	// the integer doesn't fit in an int64.
+	Big *big.Int
 }
 
+// BigIntegerMode controls when the decoder uses `*big.Int` for integers.
+type BigIntegerMode int
+
+const (
+	// BigIntegersNever rejects integers that don't fit in an int64.
+	BigIntegersNever BigIntegerMode = iota
+
+	// BigIntegersOnOverflow uses `*big.Int` for integers that don't fit in an
+	// int64.
+	BigIntegersOnOverflow
+
+	// BigIntegersAlways uses `*big.Int` for all integers.
+	BigIntegersAlways
+)
+
 type TokenEnd struct {
 	baseToken
 }
@@ -114,6 +450,7 @@ type TokenEnd struct {
 var DefaultDecoderOptions = DecoderOptions{
 	MaxIntegerLength: 64 * 1024,
 	MaxStringLength:  16 * 1024 * 1024,
+	MaxDepth:         256,
 }
 
 type DecoderOptions struct {
@@ -133,24 +470,158 @@ type DecoderOptions struct {
 	// bytes long.
 	//
 	// The parsing of byte strings is NOT affected by `MaxIntegerLength`. When
-	// reading a byte string's prefix, the decoder will read at most
-	// `len(fmt.Sprintf("%d",MaxStringLength))` bytes for the length part, and
-	// will expect a `:` byte after that.
+	// reading a byte string's prefix, the decoder will read at most 19 bytes
+	// for the length part, which is enough for any length that fits in an
+	// int64, and will expect a `:` byte after that.
 	//
 	// For example, if `MaxStringLength=9`, then `12:Lorem ipsum.` will return
-	// an error because it will read at most 1 byte for the length (because
-	// `len("9")==1`), but since the next byte is not a delimiter (it's a `2`
-	// instead of `:`), it will stop reading right there and return an error.
+	// an `ErrStringTooLong`, because its length is 12, without reading its
+	// contents into memory.
 	MaxStringLength int64
+
+	// MaxDepth is the maximum amount of dictionaries and lists that can be
+	// nested inside each other. Zero means no limit.
+	//
+	// For example, `lli1eee` needs `MaxDepth >= 2`.
+	MaxDepth int
+
+	// MaxTotalBytes is the maximum amount of bytes that a single top-level
+	// value can span, including delimiters. Zero means no limit.
+	MaxTotalBytes int64
+
+	// MaxTotalStringBytes is the maximum sum of the lengths of all the
+	// strings in a single top-level value, excluding their prefixes. Zero
+	// means no limit.
+	//
+	// Unlike `MaxStringLength`, which limits each string on its own, this
+	// limits the memory needed to hold all the strings of a value.
+	MaxTotalStringBytes int64
+
+	// MaxElements is the maximum amount of items in a single list, or keys in
+	// a single dictionary. Zero means no limit.
+	MaxElements int
+
+	// CopyStrings makes decoders created with `NewBytesDecoder` copy the
+	// contents of strings, instead of returning sub-slices of the input.
+	//
+	// By default, `TokenString.Value`, the `Raw` bytes of every token, and the
+	// strings returned by `Decode`, share memory with the input, so they are
+	// only valid as long as the input is not modified. Setting this option
+	// makes strings safe to retain after reusing the input buffer.
+	//
+	// Decoders that read from an `io.Reader` always copy.
+	CopyStrings bool
+
+	// StringReaderThreshold, if greater than zero, makes the decoder return
+	// strings of at least this many bytes as `TokenStringReader` tokens,
+	// whose payload can be read without holding it in memory. Those strings
+	// are not limited by `MaxStringLength`, unless they are decoded into a
+	// value. Dictionary keys are never returned as `TokenStringReader`.
+	StringReaderThreshold int64
+
+	// SkipLongStrings makes the decoder discard strings longer than
+	// `MaxStringLength` instead of returning `ErrStringTooLong`. They are
+	// returned as `TokenSkippedString` tokens, which only hold their length,
+	// and `Decode` returns those tokens in place of the strings.
+	//
+	// `DecodeInto` stores skipped strings in empty interfaces and in
+	// `TokenSkippedString` targets, and returns an error wrapping
+	// `ErrStringTooLong` for other targets. `DecodeValue` always returns that
+	// error, because `Value` has no representation for a skipped string.
+	//
+	// Dictionary keys are never skipped.
+	SkipLongStrings bool
+
+	// BigIntegers controls when integers are decoded as `*big.Int`, in
+	// `TokenInteger.Big`, and as `BigInteger` by `Decode`. By default,
+	// integers that don't fit in an int64 are rejected.
+	BigIntegers BigIntegerMode
+
+	// AllowNonCanonicalIntegers makes the decoder accept integers and string
+	// lengths with leading zeros, like `i03e` and `03:abc`, and negative zero,
+	// like `i-0e`, which are emitted by some old encoders. Integers without
+	// digits are always rejected.
+	AllowNonCanonicalIntegers bool
+
+	// Strict makes the decoder only accept input in canonical form.
+	//
+	// In strict mode, dictionary keys must be sorted by their raw bytes and
+	// appear only once, and the input must contain a single top-level value,
+	// with nothing after it.
+	Strict bool
+}
+
+// decoderFrame holds the state of a dictionary or list that has been opened
+// but not closed yet.
+type decoderFrame struct {
+	isDictionary bool
+	offset       int64
+
+	// expectKey is true when the next token in a dictionary must be a key.
+	expectKey bool
+
+	// length is the amount of items in a list, or keys in a dictionary, that
+	// have been read so far.
+	length int
+
+	lastKey       []byte
+	lastKeyOffset int64
+}
+
+// pathElement returns the path element of the item that is being read.
+func (f *decoderFrame) pathElement() (string, bool) {
+	if f.length == 0 {
+		return "", false
+	}
+
+	if f.isDictionary {
+		return string(f.lastKey), true
+	}
+
+	return strconv.Itoa(f.length - 1), true
 }
 
 type Decoder struct {
-	r io.Reader
+	r *bufio.Reader
+
+	// data holds the whole input when decoding from a byte slice, in which
+	// case `r` is nil.
+	data []byte
 
 	options DecoderOptions
 
 	offset int64
 	isEOF  bool
+
+	stack []*decoderFrame
+
+	// isComplete is true after a complete top-level value has been read.
+	isComplete bool
+
+	// valueOffset is the offset where the current top-level value starts, and
+	// stringBytes is the sum of the lengths of its strings so far.
+	valueOffset int64
+	stringBytes int64
+
+	// isUnmarshaling is true while running `DecodeInto`, which can store
+	// integers that don't fit in an int64.
+	isUnmarshaling bool
+
+	// isSkipping is true while skipping a value, so strings can be discarded
+	// instead of read.
+	isSkipping bool
+
+	// stringReader is the reader of the last `TokenStringReader`, if its
+	// payload hasn't been discarded yet.
+	stringReader *stringReader
+
+	// pendingSkip is a string that was rejected with `pendingSkipErr`, whose
+	// payload has not been read yet.
+	pendingSkip    *TokenSkippedString
+	pendingSkipErr error
+
+	// recent holds the last bytes read from `r`, for `SyntaxError.Excerpt`.
+	recent recentBytes
 }
 
 // Token decodes a new token.
@@ -158,236 +629,1087 @@ type Decoder struct {
 // One of the returned values is always nil. That means it returns _either_ a
 // valid token and a nil error, or a nil token and a non-nil error.
 func (d *Decoder) Token() (Token, error) {
-	if d.isEOF {
-		return nil, io.EOF
+	if err := d.closeStringReader(); err != nil {
+		return nil, err
 	}
 
-	var (
-		err error
-		n   int
-	)
+	if d.pendingSkip != nil {
+		return nil, d.pendingSkipErr
+	}
+
+	if d.options.Strict && d.isComplete {
+		offset := d.offset
+
+		_, err := d.readToken()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+
+		return nil, &ErrTrailingData{
+			Offset: offset,
+		}
+	}
+
+	if len(d.stack) == 0 {
+		d.valueOffset = d.offset
+		d.stringBytes = 0
+	}
+
+	token, err := d.readToken()
+	if err == io.EOF && len(d.stack) > 0 {
+		return nil, d.unexpectedEnd(d.offset, io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.advance(token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// checkStringBudget returns an error if reading a string of `length` bytes,
+// whose payload starts at the current offset, would exceed a budget.
+//
+// If `allocates` is false, the string is not going to be held in memory, so
+// it doesn't count towards `MaxTotalStringBytes`.
+func (d *Decoder) checkStringBudget(tokenOffset int64, length int64, allocates bool) error {
+	if limit := d.options.MaxTotalBytes; limit > 0 && d.offset+length-d.valueOffset > limit {
+		return &ErrBudgetExceeded{
+			Offset: tokenOffset,
+			Budget: "MaxTotalBytes",
+			Limit:  limit,
+			Path:   d.nextPath(),
+		}
+	}
+
+	if !allocates {
+		return nil
+	}
+
+	if limit := d.options.MaxTotalStringBytes; limit > 0 && d.stringBytes+length > limit {
+		return &ErrBudgetExceeded{
+			Offset: tokenOffset,
+			Budget: "MaxTotalStringBytes",
+			Limit:  limit,
+			Path:   d.nextPath(),
+		}
+	}
+
+	d.stringBytes += length
+
+	return nil
+}
+
+// nextPath returns the path of the value that the next token belongs to. For
+// a dictionary key, it's the path of the dictionary.
+func (d *Decoder) nextPath() Path {
+	if len(d.stack) == 0 {
+		return nil
+	}
+
+	p := d.pathTo(len(d.stack) - 1)
+
+	f := d.stack[len(d.stack)-1]
+	if !f.isDictionary {
+		p = append(p, strconv.Itoa(f.length))
+	} else if !f.expectKey {
+		p = append(p, string(f.lastKey))
+	}
+
+	return p
+}
+
+// Path returns the path of the value that the most recently read token
+// belongs to. For a dictionary key, it's the path of its value. For an end
+// token, it's the path of the dictionary or list that was closed.
+func (d *Decoder) Path() Path {
+	return d.pathTo(len(d.stack))
+}
+
+// pathTo returns the path of the container at the given depth.
+func (d *Decoder) pathTo(depth int) Path {
+	var p Path
+
+	for _, f := range d.stack[:depth] {
+		if element, ok := f.pathElement(); ok {
+			p = append(p, element)
+		}
+	}
+
+	return p
+}
+
+func (d *Decoder) unexpectedEnd(offset int64, cause error) error {
+	if len(d.stack) == 0 {
+		return &ErrUnexpectedEnd{
+			Offset:          offset,
+			ContainerOffset: -1,
+			Err:             cause,
+		}
+	}
+
+	f := d.stack[len(d.stack)-1]
+
+	err := &ErrUnexpectedEnd{
+		Offset:          offset,
+		ContainerOffset: f.offset,
+		Path:            d.pathTo(len(d.stack) - 1),
+		Err:             cause,
+	}
+
+	if f.isDictionary && !f.expectKey {
+		err.Key = append([]byte{}, f.lastKey...)
+		err.KeyOffset = f.lastKeyOffset
+	}
+
+	return err
+}
+
+// advance updates the state of the open containers with `token`, and returns
+// an error if `token` is not valid at the current position.
+func (d *Decoder) advance(token Token) error {
+	_, isEnd := token.(*TokenEnd)
+
+	if limit := d.options.MaxTotalBytes; limit > 0 && d.offset-d.valueOffset > limit {
+		return &ErrBudgetExceeded{
+			Offset: token.Offset(),
+			Budget: "MaxTotalBytes",
+			Limit:  limit,
+			Path:   d.nextPath(),
+		}
+	}
+
+	if len(d.stack) == 0 {
+		if isEnd {
+			return d.unexpectedEnd(token.Offset(), nil)
+		}
+	} else {
+		f := d.stack[len(d.stack)-1]
+
+		if isEnd {
+			if f.isDictionary && !f.expectKey {
+				return d.unexpectedEnd(token.Offset(), nil)
+			}
+
+			d.stack = d.stack[:len(d.stack)-1]
+			d.isComplete = len(d.stack) == 0
+
+			return nil
+		}
+
+		if f.isDictionary && f.expectKey {
+			key, ok := token.(*TokenString)
+			if !ok {
+				return &ErrNonStringKey{
+					Raw:              append([]byte{}, token.Raw()...),
+					Offset:           token.Offset(),
+					DictionaryOffset: f.offset,
+					Path:             d.pathTo(len(d.stack) - 1),
+				}
+			}
+
+			if d.options.Strict {
+				if err := d.checkKeyOrder(f, key); err != nil {
+					return err
+				}
+			}
+
+			if err := d.checkElements(f, token); err != nil {
+				return err
+			}
+
+			f.lastKey = append(f.lastKey[:0], key.Value...)
+			f.lastKeyOffset = key.Offset()
+			f.expectKey = false
+
+			return nil
+		}
+
+		if f.isDictionary {
+			f.expectKey = true
+		} else if err := d.checkElements(f, token); err != nil {
+			return err
+		}
+	}
+
+	switch token.(type) {
+	case *TokenDictionaryStart, *TokenListStart:
+		if d.options.MaxDepth > 0 && len(d.stack) >= d.options.MaxDepth {
+			return &ErrMaxDepthExceeded{
+				Offset:   token.Offset(),
+				MaxDepth: d.options.MaxDepth,
+				Path:     d.Path(),
+			}
+		}
+	}
+
+	switch token.(type) {
+	case *TokenDictionaryStart:
+		d.stack = append(d.stack, &decoderFrame{
+			isDictionary: true,
+			offset:       token.Offset(),
+			expectKey:    true,
+		})
+	case *TokenListStart:
+		d.stack = append(d.stack, &decoderFrame{
+			offset: token.Offset(),
+		})
+	default:
+		d.isComplete = len(d.stack) == 0
+	}
+
+	return nil
+}
+
+// checkElements counts a new element in `f`, and returns an error if there
+// are too many.
+func (d *Decoder) checkElements(f *decoderFrame, token Token) error {
+	f.length++
+
+	if limit := d.options.MaxElements; limit > 0 && f.length > limit {
+		return &ErrBudgetExceeded{
+			Offset: token.Offset(),
+			Budget: "MaxElements",
+			Limit:  int64(limit),
+			Path:   d.pathTo(len(d.stack) - 1),
+		}
+	}
+
+	return nil
+}
+
+// checkKeyOrder returns an error unless `key` is greater than the previous
+// key of the dictionary.
+func (d *Decoder) checkKeyOrder(f *decoderFrame, key *TokenString) error {
+	if f.length == 0 {
+		return nil
+	}
+
+	switch bytes.Compare(key.Value, f.lastKey) {
+	case 0:
+		return &ErrDuplicateKey{
+			Key:            append([]byte{}, key.Value...),
+			Offset:         key.Offset(),
+			PreviousOffset: f.lastKeyOffset,
+			Path:           d.pathTo(len(d.stack) - 1),
+		}
+	case -1:
+		return &ErrUnsortedKey{
+			Key:            append([]byte{}, key.Value...),
+			Offset:         key.Offset(),
+			PreviousKey:    append([]byte{}, f.lastKey...),
+			PreviousOffset: f.lastKeyOffset,
+			Path:           d.pathTo(len(d.stack) - 1),
+		}
+	}
+
+	return nil
+}
+
+// finish checks that there's nothing left after a top-level value, if the
+// decoder is in strict mode.
+func (d *Decoder) finish() error {
+	if !d.options.Strict || !d.isComplete {
+		return nil
+	}
+
+	_, err := d.Token()
+	if err == io.EOF {
+		return nil
+	}
+
+	return err
+}
+
+// readToken reads the next token from the input, without checking whether it
+// is valid at the current position.
+func (d *Decoder) readToken() (Token, error) {
+	if d.isEOF {
+		return nil, io.EOF
+	}
+
+	c, err := d.readByte()
+	if err == io.EOF {
+		d.isEOF = true
+
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+
+	tokenOffset := d.offset
+	d.offset++
+
+	if c == 'e' {
+		t := &TokenEnd{
+			baseToken: baseToken{
+				offset: tokenOffset,
+				raw:    d.delimiterRaw(tokenOffset, 'e'),
+			},
+		}
+
+		return t, nil
+	} else if c == 'i' {
+		rawNumber := []byte{}
+
+		lastReadByte := c
+		for len(rawNumber) <= d.options.MaxIntegerLength {
+			isFirstByte := len(rawNumber) == 0
+
+			expected := "digit or 'e'"
+			if isFirstByte {
+				expected = "digit, '-' or 'e'"
+			}
+
+			lastReadByte, err = d.readByte()
+			if err != nil {
+				if err == io.EOF {
+					d.isEOF = true
+
+					return nil, d.syntaxError(d.offset, nil, expected, io.ErrUnexpectedEOF)
+				} else {
+					return nil, fmt.Errorf("read error: %w", err)
+				}
+			}
+
+			d.offset++
+
+			if lastReadByte == 'e' {
+				break
+			}
+
+			isValid := (isFirstByte && lastReadByte == '-') || (lastReadByte >= '0' && lastReadByte <= '9')
+			if !isValid {
+				err := &ErrInvalidToken{
+					Offset: tokenOffset,
+					Path:   d.nextPath(),
+				}
+
+				return nil, d.syntaxError(d.offset-1, []byte{lastReadByte}, expected, err)
+			}
+
+			rawNumber = append(rawNumber, lastReadByte)
+		}
+
+		if lastReadByte != 'e' {
+			err := &ErrUnexpectedByte{
+				Offset:   tokenOffset,
+				Got:      lastReadByte,
+				Expected: 'e',
+				Path:     d.nextPath(),
+			}
+
+			return nil, d.syntaxError(d.offset-1, []byte{lastReadByte}, "'e'", err)
+		}
+
+		tokenBytes := append(append([]byte{c}, rawNumber...), 'e')
+
+		if err := d.checkInteger(tokenOffset, rawNumber); err != nil {
+			return nil, d.syntaxError(tokenOffset, tokenBytes, "canonical integer", err)
+		}
+
+		var (
+			parsedNumber int64
+			bigNumber    *big.Int
+		)
+
+		parsedNumber, err = strconv.ParseInt(string(rawNumber), 10, 64)
+		if err != nil {
+			if !errors.Is(err, strconv.ErrRange) || (d.options.BigIntegers == BigIntegersNever && !d.isUnmarshaling) {
+				return nil, d.syntaxError(tokenOffset, tokenBytes, "integer that fits in an int64", err)
+			}
+
+			// The digits have already been validated.
+			bigNumber, _ = new(big.Int).SetString(string(rawNumber), 10)
+			parsedNumber = 0
+		} else if d.options.BigIntegers == BigIntegersAlways {
+			bigNumber = big.NewInt(parsedNumber)
+		}
+
+		raw := tokenBytes
+		if d.r == nil && !d.options.CopyStrings {
+			raw = d.data[tokenOffset:d.offset:d.offset]
+		}
+
+		t := &TokenInteger{
+			baseToken: baseToken{
+				offset: tokenOffset,
+				raw:    raw,
+			},
+			Value: parsedNumber,
+			Big:   bigNumber,
+		}
+
+		return t, nil
+	} else if c == 'd' {
+		t := &TokenDictionaryStart{
+			baseToken: baseToken{
+				offset: tokenOffset,
+				raw:    d.delimiterRaw(tokenOffset, 'd'),
+			},
+		}
+
+		return t, nil
+	} else if c == 'l' {
+		t := &TokenListStart{
+			baseToken: baseToken{
+				offset: tokenOffset,
+				raw:    d.delimiterRaw(tokenOffset, 'l'),
+			},
+		}
+
+		return t, nil
+	} else if c >= '0' && c <= '9' {
+		// Read any length that fits in an int64, so strings longer than
+		// `MaxStringLength` are reported with `ErrStringTooLong`, and can be
+		// skipped.
+		maxLengthBytes := decimalLength(math.MaxInt64)
+
+		rawLength := []byte{c}
+
+		lastReadByte := c
+		for len(rawLength) <= maxLengthBytes {
+			lastReadByte, err = d.readByte()
+			if err != nil {
+				if err == io.EOF {
+					d.isEOF = true
+
+					return nil, d.syntaxError(d.offset, nil, "digit or ':'", io.ErrUnexpectedEOF)
+				} else {
+					return nil, fmt.Errorf("read error: %w", err)
+				}
+			}
+
+			d.offset++
+
+			if lastReadByte == ':' {
+				break
+			}
+
+			isValid := (lastReadByte >= '0' && lastReadByte <= '9')
+			if !isValid {
+				err := &ErrInvalidToken{
+					Offset: tokenOffset,
+					Path:   d.nextPath(),
+				}
+
+				return nil, d.syntaxError(d.offset-1, []byte{lastReadByte}, "digit or ':'", err)
+			}
+
+			rawLength = append(rawLength, lastReadByte)
+		}
+
+		if lastReadByte != ':' {
+			err := &ErrUnexpectedByte{
+				Offset:   tokenOffset,
+				Got:      lastReadByte,
+				Expected: ':',
+				Path:     d.nextPath(),
+			}
+
+			return nil, d.syntaxError(d.offset-1, []byte{lastReadByte}, "':'", err)
+		}
+
+		if len(rawLength) > 1 && rawLength[0] == '0' && !d.options.AllowNonCanonicalIntegers {
+			err := &ErrLeadingZero{
+				Offset: tokenOffset,
+				Path:   d.nextPath(),
+			}
+
+			return nil, d.syntaxError(tokenOffset, rawLength, "string length without leading zeros", err)
+		}
+
+		var parsedLength int64
+
+		parsedLength, err = strconv.ParseInt(string(rawLength), 10, 64)
+		if err != nil {
+			return nil, d.syntaxError(tokenOffset, rawLength, "string length that fits in an int64", err)
+		}
+
+		threshold := d.options.StringReaderThreshold
+		if threshold > 0 && parsedLength >= threshold && !d.isSkipping && !d.isKeyExpected() {
+			return d.newStringReader(tokenOffset, rawLength, parsedLength)
+		}
+
+		isTooLong := parsedLength > d.options.MaxStringLength
+		isSkippable := (d.isSkipping || (isTooLong && d.options.SkipLongStrings)) && !d.isKeyExpected()
+
+		if isSkippable || isTooLong {
+			t := &TokenSkippedString{
+				offset: tokenOffset,
+				Length: parsedLength,
+			}
+
+			if d.r == nil && !d.options.CopyStrings {
+				t.raw = d.data[tokenOffset:d.offset:d.offset]
+			} else {
+				t.raw = append(rawLength, ':')
+			}
+
+			if isSkippable {
+				if err := d.discardPayload(t); err != nil {
+					return nil, err
+				}
+
+				return t, nil
+			}
+
+			// Leave the payload for `SkipValue`.
+			d.pendingSkip = t
+			d.pendingSkipErr = &ErrStringTooLong{
+				TokenOffset:     tokenOffset,
+				NextTokenOffset: d.offset + parsedLength,
+				Path:            d.nextPath(),
+			}
+
+			return nil, d.pendingSkipErr
+		}
+
+		if err := d.checkStringBudget(tokenOffset, parsedLength, true); err != nil {
+			return nil, err
+		}
+
+		prefixLength := d.offset - tokenOffset
+
+		var raw []byte
+
+		if d.r == nil {
+			raw, err = d.sliceInput(tokenOffset, parsedLength)
+		} else {
+			raw, err = d.readPayload(append(rawLength, ':'), parsedLength)
+		}
+		if err != nil {
+			return nil, d.payloadError(tokenOffset, parsedLength, err)
+		}
+
+		t := &TokenString{
+			offset: tokenOffset,
+			raw:    raw,
+			Value:  raw[prefixLength:],
+		}
+
+		return t, nil
+	} else {
+		err := &ErrInvalidToken{
+			Offset: tokenOffset,
+			Path:   d.nextPath(),
+		}
+
+		return nil, d.syntaxError(tokenOffset, []byte{c}, "'i', 'l', 'd', 'e' or digit", err)
+	}
+}
+
+// newStringReader returns a token for a string whose payload is left to be
+// read through `TokenStringReader.Reader`.
+func (d *Decoder) newStringReader(tokenOffset int64, rawLength []byte, length int64) (*TokenStringReader, error) {
+	if err := d.checkStringBudget(tokenOffset, length, false); err != nil {
+		return nil, err
+	}
+
+	if d.r == nil && length > int64(len(d.data))-d.offset {
+		d.offset = int64(len(d.data))
+
+		return nil, d.payloadError(tokenOffset, length, io.ErrUnexpectedEOF)
+	}
+
+	sr := &stringReader{
+		d:           d,
+		remaining:   length,
+		tokenOffset: tokenOffset,
+		length:      length,
+	}
+
+	t := &TokenStringReader{
+		offset: tokenOffset,
+		Length: length,
+		Reader: sr,
+	}
+
+	if d.r == nil && !d.options.CopyStrings {
+		t.raw = d.data[tokenOffset:d.offset:d.offset]
+	} else {
+		t.raw = append(rawLength, ':')
+	}
+
+	d.stringReader = sr
+
+	return t, nil
+}
+
+// closeStringReader invalidates the reader of the last `TokenStringReader`,
+// and discards the part of its payload that hasn't been read.
+func (d *Decoder) closeStringReader() error {
+	sr := d.stringReader
+	if sr == nil {
+		return nil
+	}
+
+	d.stringReader = nil
+	sr.d = nil
+
+	if d.r == nil {
+		d.offset += sr.remaining
+
+		return nil
+	}
+
+	n, err := io.CopyN(&d.recent, d.r, sr.remaining)
+	d.offset += n
+	if err != nil {
+		return d.payloadErrorAfter(sr.tokenOffset, sr.length, err)
+	}
+
+	return nil
+}
+
+// readString reads the whole payload of `t` into memory, for the methods that
+// decode complete values.
+//
+// If the string is longer than `MaxStringLength`, it's discarded and returned
+// as a `TokenSkippedString` if `SkipLongStrings` is set, or an
+// `ErrStringTooLong` is returned otherwise.
+func (d *Decoder) readString(t *TokenStringReader) (Token, error) {
+	sr := d.stringReader
+	if sr == nil || sr != t.Reader || sr.remaining != t.Length {
+		return nil, fmt.Errorf("payload of string at offset %d has already been read", t.offset)
+	}
+
+	if t.Length > d.options.MaxStringLength {
+		if !d.options.SkipLongStrings {
+			return nil, &ErrStringTooLong{
+				TokenOffset:     t.offset,
+				NextTokenOffset: d.offset + t.Length,
+				Path:            d.Path(),
+			}
+		}
+
+		if err := d.closeStringReader(); err != nil {
+			return nil, err
+		}
+
+		skipped := &TokenSkippedString{
+			offset: t.offset,
+			raw:    t.raw,
+			Length: t.Length,
+		}
+
+		return skipped, nil
+	}
+
+	if err := d.checkStringBudget(t.offset, t.Length, true); err != nil {
+		// `t` has already been read, so it's no longer the next value.
+		if budgetErr, ok := err.(*ErrBudgetExceeded); ok {
+			budgetErr.Path = d.Path()
+		}
+
+		return nil, err
+	}
+
+	d.stringReader = nil
+	sr.d = nil
+
+	var (
+		err error
+		raw []byte
+	)
+
+	if d.r == nil {
+		raw, err = d.sliceInput(t.offset, t.Length)
+	} else {
+		raw, err = d.readPayload(t.raw, t.Length)
+	}
+	if err != nil {
+		return nil, d.payloadErrorAfter(t.offset, t.Length, err)
+	}
+
+	ts := &TokenString{
+		offset: t.offset,
+		raw:    raw,
+		Value:  raw[len(t.raw):],
+	}
+
+	return ts, nil
+}
+
+// checkInteger returns an error if `digits`, the contents of the integer
+// token at `tokenOffset`, is not a canonical integer.
+func (d *Decoder) checkInteger(tokenOffset int64, digits []byte) error {
+	isNegative := len(digits) > 0 && digits[0] == '-'
+	if isNegative {
+		digits = digits[1:]
+	}
+
+	if len(digits) == 0 {
+		return &ErrEmptyInteger{
+			Offset: tokenOffset,
+			Path:   d.nextPath(),
+		}
+	}
+
+	if d.options.AllowNonCanonicalIntegers {
+		return nil
+	}
+
+	if digits[0] != '0' {
+		return nil
+	}
+
+	if isNegative && bytes.Count(digits, []byte{'0'}) == len(digits) {
+		return &ErrNegativeZero{
+			Offset: tokenOffset,
+			Path:   d.nextPath(),
+		}
+	}
+
+	if len(digits) > 1 {
+		return &ErrLeadingZero{
+			Offset: tokenOffset,
+			Path:   d.nextPath(),
+		}
+	}
+
+	return nil
+}
+
+// excerptRadius is the amount of bytes before and after the offending bytes
+// that are included in `SyntaxError.Excerpt`.
+const excerptRadius = 8
+
+// maxSyntaxErrorGot is the maximum amount of bytes kept in `SyntaxError.Got`.
+const maxSyntaxErrorGot = 32
+
+// recentBytesSize is the amount of bytes kept by `recentBytes`, which is
+// enough for an excerpt around the longest `SyntaxError.Got`.
+const recentBytesSize = 2*excerptRadius + maxSyntaxErrorGot
+
+// recentBytes is an `io.Writer` that keeps the last `recentBytesSize` bytes
+// written to it, in a ring buffer.
+type recentBytes struct {
+	buf [recentBytesSize]byte
+
+	// n is the total amount of bytes written.
+	n int64
+}
+
+func (r *recentBytes) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if len(p) > recentBytesSize {
+		r.n += int64(len(p) - recentBytesSize)
+		p = p[len(p)-recentBytesSize:]
+	}
+
+	for len(p) > 0 {
+		copied := copy(r.buf[r.n%recentBytesSize:], p)
+		r.n += int64(copied)
+		p = p[copied:]
+	}
+
+	return n, nil
+}
+
+func (r *recentBytes) writeByte(c byte) {
+	r.buf[r.n%recentBytesSize] = c
+	r.n++
+}
+
+// bytes returns a copy of the last bytes written, up to `recentBytesSize`.
+func (r *recentBytes) bytes() []byte {
+	if r.n < recentBytesSize {
+		return append([]byte{}, r.buf[:r.n]...)
+	}
+
+	i := r.n % recentBytesSize
+
+	return append(append([]byte{}, r.buf[i:]...), r.buf[:i]...)
+}
+
+// syntaxError returns a `SyntaxError` for the bytes `got` at `offset`.
+func (d *Decoder) syntaxError(offset int64, got []byte, expected string, err error) *SyntaxError {
+	var (
+		excerpt       []byte
+		excerptOffset int64
+	)
+
+	isGotTruncated := len(got) > maxSyntaxErrorGot
+	if isGotTruncated {
+		got = got[:maxSyntaxErrorGot]
+	}
+
+	if d.r == nil {
+		start := offset - excerptRadius
+		if start < 0 {
+			start = 0
+		}
+
+		end := offset + int64(len(got)) + excerptRadius
+		if end > int64(len(d.data)) {
+			end = int64(len(d.data))
+		}
+
+		excerpt = d.data[start:end]
+		excerptOffset = start
+	} else {
+		history := d.recent.bytes()
+		historyOffset := d.offset - int64(len(history))
+
+		start := offset - excerptRadius
+		if start < historyOffset {
+			start = historyOffset
+		}
 
-	prefix := make([]byte, 1)
+		end := offset + int64(len(got)) + excerptRadius
+		if end <= start {
+			// The offending bytes are no longer in `history`, so show the
+			// bytes around the current position instead.
+			start = d.offset - excerptRadius
+			if start < historyOffset {
+				start = historyOffset
+			}
 
-	n, err = d.r.Read(prefix)
-	if err == io.EOF {
-		d.isEOF = true
+			end = d.offset + excerptRadius
+		}
 
-		if n == 0 {
-			return nil, io.EOF
+		historyEnd := end
+		if historyEnd > d.offset {
+			historyEnd = d.offset
 		}
 
-		// If something was read, continue.
-		err = nil
-	}
-	if err != nil {
-		return nil, fmt.Errorf("error reading: %#v", err)
+		excerpt = append([]byte{}, history[start-historyOffset:historyEnd-historyOffset]...)
+		excerptOffset = start
+
+		if end > d.offset {
+			// The error is ignored, because the excerpt can be shorter at
+			// the end of the input.
+			next, _ := d.r.Peek(int(end - d.offset))
+
+			excerpt = append(excerpt, next...)
+		}
 	}
 
-	tokenOffset := d.offset
-	d.offset += int64(n)
+	return &SyntaxError{
+		Offset:   offset,
+		Got:      append([]byte{}, got...),
+		Expected: expected,
+		Excerpt:  formatExcerpt(excerptOffset, excerpt),
+		Path:     d.nextPath(),
+		Err:      err,
 
-	c := prefix[0]
-	if c == 'e' {
-		t := &TokenEnd{
-			baseToken: baseToken{
-				offset: tokenOffset,
-				raw:    []byte{'e'},
-			},
+		isGotTruncated: isGotTruncated,
+	}
+}
+
+// formatExcerpt formats `b`, which starts at `offset`, as a line of hex and
+// ASCII, like `hexdump -C`.
+func formatExcerpt(offset int64, b []byte) string {
+	printable := make([]byte, len(b))
+	for i, c := range b {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
 		}
 
-		return t, nil
-	} else if c == 'i' {
-		rawNumber := []byte{}
+		printable[i] = c
+	}
 
-		readBuffer := make([]byte, 1)
+	return fmt.Sprintf("%08x  % x  |%s|", offset, b, printable)
+}
 
-		lastReadByte := c
-		for len(rawNumber) <= d.options.MaxIntegerLength {
-			isFirstByte := len(rawNumber) == 0
+// payloadError returns the error for a failure to read the payload of the
+// string at `tokenOffset`, which is `length` bytes long. If the input ended,
+// it's a `*SyntaxError`.
+func (d *Decoder) payloadError(tokenOffset int64, length int64, err error) error {
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("read error: %w", err)
+	}
 
-			n, err = d.r.Read(readBuffer)
-			d.offset += int64(n)
-			if err != nil {
-				if err == io.EOF {
-					d.isEOF = true
+	expected := fmt.Sprintf("the rest of the %d-byte string at offset %d", length, tokenOffset)
 
-					return nil, fmt.Errorf("unexpected EOF: %w", err)
-				} else {
-					return nil, fmt.Errorf("read error: %w", err)
-				}
-			}
+	return d.syntaxError(d.offset, nil, expected, io.ErrUnexpectedEOF)
+}
 
-			lastReadByte = readBuffer[0]
-			if lastReadByte == 'e' {
-				break
-			}
+// payloadErrorAfter is like `payloadError`, for strings whose token has
+// already been returned.
+func (d *Decoder) payloadErrorAfter(tokenOffset int64, length int64, err error) error {
+	err = d.payloadError(tokenOffset, length, err)
+	if syntaxErr, ok := err.(*SyntaxError); ok {
+		syntaxErr.Path = d.Path()
+	}
 
-			isValid := (isFirstByte && lastReadByte == '-') || (lastReadByte >= '0' && lastReadByte <= '9')
-			if !isValid {
-				err := &ErrInvalidToken{
-					Offset: tokenOffset,
-				}
+	return err
+}
 
-				return nil, err
-			}
+// discardPayload discards the payload of a skipped string, whose prefix has
+// just been read.
+func (d *Decoder) discardPayload(t *TokenSkippedString) error {
+	if err := d.checkStringBudget(t.offset, t.Length, false); err != nil {
+		return err
+	}
 
-			rawNumber = append(rawNumber, lastReadByte)
+	if d.r == nil {
+		if t.Length > int64(len(d.data))-d.offset {
+			d.offset = int64(len(d.data))
+
+			return d.payloadError(t.offset, t.Length, io.ErrUnexpectedEOF)
 		}
 
-		if lastReadByte != 'e' {
-			err := &ErrUnexpectedByte{
-				Offset:   tokenOffset,
-				Got:      lastReadByte,
-				Expected: 'e',
-			}
+		d.offset += t.Length
 
-			return nil, err
-		}
+		return nil
+	}
 
-		var parsedNumber int64
+	n, err := io.CopyN(&d.recent, d.r, t.Length)
+	d.offset += n
+	if err != nil {
+		return d.payloadError(t.offset, t.Length, err)
+	}
 
-		parsedNumber, err = strconv.ParseInt(string(rawNumber), 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("could not parse byte string length: %#v", err)
-		}
+	return nil
+}
 
-		raw := []byte{'i'}
-		raw = append(raw, []byte(fmt.Sprintf("%d", parsedNumber))...)
-		raw = append(raw, 'e')
+// isKeyExpected reports whether the next token must be a dictionary key.
+func (d *Decoder) isKeyExpected() bool {
+	if len(d.stack) == 0 {
+		return false
+	}
 
-		if fmt.Sprintf("i%se", string(rawNumber)) != string(raw) {
-			err := &ErrInvalidToken{
-				Offset: tokenOffset,
-			}
+	f := d.stack[len(d.stack)-1]
 
-			return nil, err
-		}
+	return f.isDictionary && f.expectKey
+}
 
-		t := &TokenInteger{
-			baseToken: baseToken{
-				offset: tokenOffset,
-				raw:    raw,
-			},
-			Value: parsedNumber,
+// readByte returns the next byte of the input, without advancing the offset.
+func (d *Decoder) readByte() (byte, error) {
+	if d.r == nil {
+		if d.offset >= int64(len(d.data)) {
+			return 0, io.EOF
 		}
 
-		return t, nil
-	} else if c == 'd' {
-		t := &TokenDictionaryStart{
-			baseToken: baseToken{
-				offset: tokenOffset,
-				raw:    []byte{'d'},
-			},
-		}
+		return d.data[d.offset], nil
+	}
 
-		return t, nil
-	} else if c == 'l' {
-		t := &TokenListStart{
-			baseToken: baseToken{
-				offset: tokenOffset,
-				raw:    []byte{'l'},
-			},
-		}
+	c, err := d.r.ReadByte()
+	if err == nil {
+		d.recent.writeByte(c)
+	}
 
-		return t, nil
-	} else if c >= '0' && c <= '9' {
-		maxLengthBytes := len(fmt.Sprintf("%d", d.options.MaxStringLength))
-		rawLength := []byte{c}
+	return c, err
+}
 
-		readBuffer := make([]byte, 1)
+// delimiterRaw returns the raw bytes of a token made of the single byte `c`,
+// which has just been read.
+func (d *Decoder) delimiterRaw(tokenOffset int64, c byte) []byte {
+	if d.r == nil && !d.options.CopyStrings {
+		return d.data[tokenOffset:d.offset:d.offset]
+	}
 
-		lastReadByte := c
-		for len(rawLength) <= maxLengthBytes {
-			n, err = d.r.Read(readBuffer)
-			d.offset += int64(n)
-			if err != nil {
-				if err == io.EOF {
-					d.isEOF = true
+	return []byte{c}
+}
 
-					return nil, fmt.Errorf("unexpected EOF: %w", err)
-				} else {
-					return nil, fmt.Errorf("read error: %w", err)
-				}
-			}
+// sliceInput returns the raw bytes of a string token, whose payload of `n`
+// bytes starts at the current offset, when decoding from a byte slice.
+func (d *Decoder) sliceInput(tokenOffset int64, n int64) ([]byte, error) {
+	if n > int64(len(d.data))-d.offset {
+		d.offset = int64(len(d.data))
 
-			lastReadByte = readBuffer[0]
-			if lastReadByte == ':' {
-				break
-			}
+		return nil, io.ErrUnexpectedEOF
+	}
 
-			isValid := (lastReadByte >= '0' && lastReadByte <= '9')
-			if !isValid {
-				err := &ErrInvalidToken{
-					Offset: tokenOffset,
-				}
+	end := d.offset + n
+	raw := d.data[tokenOffset:end:end]
+	d.offset = end
 
-				return nil, err
-			}
+	if d.options.CopyStrings {
+		raw = append([]byte(nil), raw...)
+	}
 
-			rawLength = append(rawLength, lastReadByte)
-		}
+	return raw, nil
+}
 
-		if lastReadByte != ':' {
-			err := &ErrUnexpectedByte{
-				Offset:   tokenOffset,
-				Got:      lastReadByte,
-				Expected: ':',
-			}
+// ownsStrings reports whether the contents of string tokens are owned by the
+// tokens, instead of being shared with the input.
+func (d *Decoder) ownsStrings() bool {
+	return d.r != nil || d.options.CopyStrings
+}
 
-			return nil, err
-		}
+// decimalLength returns `len(fmt.Sprintf("%d", n))` without allocating.
+func decimalLength(n int64) int {
+	length := 1
+	if n < 0 {
+		length++
+		n = -n
+	}
 
-		var parsedLength int64
+	for n >= 10 {
+		n /= 10
+		length++
+	}
 
-		parsedLength, err = strconv.ParseInt(string(rawLength), 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("could not parse byte string length: %#v", err)
-		}
-		if parsedLength > d.options.MaxStringLength {
-			err := &ErrStringTooLong{
-				TokenOffset:     tokenOffset,
-				NextTokenOffset: d.offset + parsedLength,
-			}
+	return length
+}
 
-			return nil, err
-		}
+// payloadChunkSize is the maximum amount of memory allocated at once when
+// reading a string, so that a string that claims to be longer than the input
+// doesn't allocate its whole length upfront.
+const payloadChunkSize = 64 * 1024
 
-		dst := &bytes.Buffer{}
+// readPayload reads `n` bytes and returns them appended to `prefix`.
+func (d *Decoder) readPayload(prefix []byte, n int64) ([]byte, error) {
+	firstChunk := n
+	if firstChunk > payloadChunkSize {
+		firstChunk = payloadChunkSize
+	}
 
-		var copiedBytes int64
+	dst := make([]byte, len(prefix), int64(len(prefix))+firstChunk)
+	copy(dst, prefix)
 
-		copiedBytes, err = io.CopyN(dst, d.r, parsedLength)
-		d.offset += copiedBytes
-		if err != nil {
-			return nil, fmt.Errorf("read error: %w", err)
-		}
-		if copiedBytes != parsedLength {
-			return nil, fmt.Errorf("unexpected read of %d bytes; wanted %d bytes", copiedBytes, parsedLength)
+	for n > 0 {
+		chunk := n
+		if chunk > payloadChunkSize {
+			chunk = payloadChunkSize
 		}
 
-		t := &TokenString{
-			offset: tokenOffset,
+		start := len(dst)
+		dst = append(dst, make([]byte, chunk)...)
+
+		read, err := io.ReadFull(d.r, dst[start:])
+		d.recent.Write(dst[start : start+read])
+		d.offset += int64(read)
+		if err != nil {
+			return nil, err
 		}
 
-		t.setValue(dst.Bytes())
+		n -= chunk
+	}
 
-		return t, nil
-	} else {
-		err := &ErrInvalidToken{
-			Offset: tokenOffset,
+	return dst, nil
+}
+
+func (d *Decoder) decodeInteger(token *TokenInteger) (interface{}, error) {
+	if token.Big != nil && d.options.BigIntegers == BigIntegersNever {
+		// Only `DecodeInto` reads integers that don't fit in an int64 in
+		// this mode.
+		return nil, &ErrIntegerOverflow{
+			Offset: token.Offset(),
+			Target: reflect.TypeOf(int64(0)),
+			Path:   d.Path(),
 		}
+	}
 
-		return nil, err
+	if token.Big != nil {
+		return BigInteger{token.Big}, nil
 	}
-}
 
-func (d *Decoder) decodeInteger(token *TokenInteger) (int64, error) {
 	return token.Value, nil
 }
 
 func (d *Decoder) decodeString(token *TokenString) (interface{}, error) {
-	// TODO: Is it necessary to copy to a new slice?
+	// No copy is needed: `token.Value` is either a fresh slice, or a
+	// sub-slice of the input of a `NewBytesDecoder`, which the caller must
+	// not modify unless `CopyStrings` is set.
 	return token.Value, nil
 }
 
@@ -408,7 +1730,7 @@ func (d *Decoder) decodeDictionary(token *TokenDictionaryStart) (map[string]inte
 
 		keyToken, err = d.Token()
 		if err != nil {
-			return nil, fmt.Errorf("could not read key token: %w", err)
+			return nil, err
 		}
 
 		if _, ok := keyToken.(*TokenEnd); ok {
@@ -417,27 +1739,20 @@ func (d *Decoder) decodeDictionary(token *TokenDictionaryStart) (map[string]inte
 			break
 		}
 
-		var key string
-		if parsedKeyToken, ok := keyToken.(*TokenString); ok {
-			key = string(parsedKeyToken.Value)
-		} else {
-			return nil, fmt.Errorf("found non-string dictionary key")
-		}
+		// `Token` only returns strings as dictionary keys, and returns an
+		// error instead of an end token where a value is expected.
+		key := string(keyToken.(*TokenString).Value)
 
 		valueToken, err = d.Token()
 		if err != nil {
-			return nil, fmt.Errorf("could not read value token: %w", err)
-		}
-
-		if _, ok := valueToken.(*TokenEnd); ok {
-			return nil, fmt.Errorf("unexpected end of dictionary")
+			return nil, err
 		}
 
 		var parsedValue interface{}
 
 		parsedValue, err = d.decodeAny(valueToken)
 		if err != nil {
-			return nil, fmt.Errorf("could not decode token: %w", err)
+			return nil, err
 		}
 
 		dst[key] = parsedValue
@@ -465,7 +1780,7 @@ func (d *Decoder) decodeList(token *TokenListStart) ([]interface{}, error) {
 
 		itemToken, err = d.Token()
 		if err != nil {
-			return nil, fmt.Errorf("could not read token: %w", err)
+			return nil, err
 		}
 
 		if _, ok := itemToken.(*TokenEnd); ok {
@@ -478,7 +1793,7 @@ func (d *Decoder) decodeList(token *TokenListStart) ([]interface{}, error) {
 
 		item, err = d.decodeAny(itemToken)
 		if err != nil {
-			return nil, fmt.Errorf("could not decode token: %w", err)
+			return nil, err
 		}
 
 		dst = append(dst, item)
@@ -497,6 +1812,15 @@ func (d *Decoder) decodeAny(token Token) (interface{}, error) {
 		return d.decodeInteger(parsedToken)
 	case *TokenString:
 		return d.decodeString(parsedToken)
+	case *TokenSkippedString:
+		return parsedToken, nil
+	case *TokenStringReader:
+		token, err := d.readString(parsedToken)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.decodeAny(token)
 	case *TokenDictionaryStart:
 		return d.decodeDictionary(parsedToken)
 	case *TokenListStart:
@@ -514,20 +1838,192 @@ func (d *Decoder) Decode() (interface{}, error) {
 
 	token, err = d.Token()
 	if err != nil {
-		return nil, fmt.Errorf("could not read token: %w", err)
+		return nil, err
+	}
+
+	var value interface{}
+
+	value, err = d.decodeAny(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// peekByte returns the next byte of the input without consuming it.
+func (d *Decoder) peekByte() (byte, error) {
+	if err := d.closeStringReader(); err != nil {
+		return 0, err
+	}
+
+	if d.pendingSkip != nil {
+		return 0, d.pendingSkipErr
+	}
+
+	if d.isEOF {
+		return 0, io.EOF
+	}
+
+	if d.r == nil {
+		return d.readByte()
+	}
+
+	b, err := d.r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+// PeekKind returns the kind of the next token, without consuming it.
+//
+// It returns `KindInvalid` and a nil error if the next byte can't start a
+// token, in which case `Token` will return an error. At the end of the input,
+// it returns `io.EOF`.
+func (d *Decoder) PeekKind() (Kind, error) {
+	c, err := d.peekByte()
+	if err != nil {
+		return KindInvalid, err
+	}
+
+	return kindOfByte(c), nil
+}
+
+// More reports whether there is another item in the current list or
+// dictionary, or another top-level value in the input. A string rejected with
+// `ErrStringTooLong` counts as an item until it's skipped.
+func (d *Decoder) More() bool {
+	if d.pendingSkip != nil {
+		return true
+	}
+
+	kind, err := d.PeekKind()
+
+	return err == nil && kind != KindEnd
+}
+
+// Skip reads and discards the next complete value.
+//
+// It returns an error without consuming anything if the next token closes a
+// list or dictionary.
+func (d *Decoder) Skip() error {
+	kind, err := d.PeekKind()
+	if err == nil && kind == KindEnd {
+		return d.unexpectedEnd(d.offset, nil)
+	}
+
+	d.isSkipping = true
+	defer func() {
+		d.isSkipping = false
+	}()
+
+	var token Token
+
+	token, err = d.Token()
+	if err != nil {
+		return err
+	}
+
+	if err := d.skip(token); err != nil {
+		return err
+	}
+
+	return d.finish()
+}
+
+// SkipValue discards the payload of a string that has just been rejected with
+// `ErrStringTooLong`, so decoding can continue after it. If there is no such
+// string, it discards the next complete value, like `Skip`.
+//
+// Strings are discarded without holding them in memory.
+func (d *Decoder) SkipValue() error {
+	t := d.pendingSkip
+	if t == nil {
+		return d.Skip()
+	}
+
+	if d.isKeyExpected() {
+		return fmt.Errorf("dictionary key at offset %d can't be skipped: %w", t.offset, d.pendingSkipErr)
+	}
+
+	d.pendingSkip = nil
+	d.pendingSkipErr = nil
+
+	if err := d.discardPayload(t); err != nil {
+		return err
+	}
+
+	if err := d.advance(t); err != nil {
+		return err
+	}
+
+	return d.finish()
+}
+
+// Buffered returns a reader of the data remaining in the decoder's buffer,
+// which has been read from the underlying reader but not decoded yet.
+//
+// The reader is valid until the next call to any other method of the decoder.
+func (d *Decoder) Buffered() io.Reader {
+	if d.r == nil {
+		return bytes.NewReader(d.data[d.offset:])
 	}
 
-	return d.decodeAny(token)
+	b, _ := d.r.Peek(d.r.Buffered())
+
+	return bytes.NewReader(b)
 }
 
 func NewDecoder(r io.Reader) *Decoder {
 	return NewDecoderWithOptions(r, DefaultDecoderOptions)
 }
 
+// NewDecoderWithOptions returns a decoder that reads from `r`.
+//
+// The decoder reads from `r` through an internal buffer, so it may read more
+// data than necessary. Use `Buffered` to recover it. If `r` is already a
+// `*bufio.Reader`, it is used directly.
 func NewDecoderWithOptions(r io.Reader, options DecoderOptions) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &Decoder{
+		r: br,
+
+		options: options,
+	}
+}
+
+// NewBytesDecoder returns a decoder that reads from `data`, which must not be
+// modified while the decoder is in use.
+//
+// Unlike decoders that read from an `io.Reader`, strings and raw token bytes
+// are returned as sub-slices of `data`, without copying them, unless
+// `DecoderOptions.CopyStrings` is set.
+func NewBytesDecoder(data []byte) *Decoder {
+	return NewBytesDecoderWithOptions(data, DefaultDecoderOptions)
+}
+
+func NewBytesDecoderWithOptions(data []byte, options DecoderOptions) *Decoder {
 	return &Decoder{
-		r: r,
+		data: data,
 
 		options: options,
 	}
 }
+
+// DecodeBytes decodes the first bencode value in `data`.
+//
+// The strings in the returned value share memory with `data`. See
+// `NewBytesDecoder`.
+func DecodeBytes(data []byte) (interface{}, error) {
+	return NewBytesDecoder(data).Decode()
+}
@@ -1,8 +1,14 @@
 package bencode_test
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/c032/go-bencode"
@@ -213,12 +219,13 @@ func TestDecoder_Token_NegativeZero(t *testing.T) {
 	if err == nil {
 		t.Error("unexpected nil err")
 	} else {
-		if parsedError, ok := err.(*bencode.ErrInvalidToken); ok {
+		var parsedError *bencode.ErrNegativeZero
+		if errors.As(err, &parsedError) {
 			if got, want := parsedError.Offset, int64(0); got != want {
-				t.Errorf("err.(*bencode.ErrInvalidToken).Offset = %#v; want %#v", got, want)
+				t.Errorf("err.(*bencode.ErrNegativeZero).Offset = %#v; want %#v", got, want)
 			}
 		} else {
-			t.Errorf("unexpected error %#v; want `*bencode.ErrInvalidToken`", err)
+			t.Errorf("unexpected error %#v; want `*bencode.ErrNegativeZero`", err)
 		}
 	}
 	if token != nil {
@@ -509,3 +516,1446 @@ func TestDecoder_Decode_DictionaryNested(t *testing.T) {
 		}
 	}
 }
+
+func TestDecoder_Decode_Strict(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.Strict = true
+
+	valid := []string{
+		"de",
+		"d1:ai1e1:bi2ee",
+		"d0:i0e1:\x00i0e1:ai0e2:aai0e1:\xffi0ee",
+		"ld1:ai1eed1:ai1eee",
+	}
+
+	for _, input := range valid {
+		d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+		if _, err := d.Decode(); err != nil {
+			t.Errorf("Decode(%#v) returned error: %s", input, err)
+		}
+	}
+}
+
+func TestDecoder_Decode_StrictDuplicateKey(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.Strict = true
+
+	input := "d1:ai1e1:bi2e1:bi3ee"
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+	_, err := d.Decode()
+
+	var dupErr *bencode.ErrDuplicateKey
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("Decode(%#v) = %#v; want *bencode.ErrDuplicateKey", input, err)
+	}
+
+	if got, want := string(dupErr.Key), "b"; got != want {
+		t.Errorf("err.Key = %#v; want %#v", got, want)
+	}
+	if got, want := dupErr.Offset, int64(13); got != want {
+		t.Errorf("err.Offset = %#v; want %#v", got, want)
+	}
+	if got, want := dupErr.PreviousOffset, int64(7); got != want {
+		t.Errorf("err.PreviousOffset = %#v; want %#v", got, want)
+	}
+}
+
+func TestDecoder_Decode_StrictUnsortedKey(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.Strict = true
+
+	input := "d2:aai1e1:ai2ee"
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+	_, err := d.Decode()
+
+	var unsortedErr *bencode.ErrUnsortedKey
+	if !errors.As(err, &unsortedErr) {
+		t.Fatalf("Decode(%#v) = %#v; want *bencode.ErrUnsortedKey", input, err)
+	}
+
+	if got, want := string(unsortedErr.Key), "a"; got != want {
+		t.Errorf("err.Key = %#v; want %#v", got, want)
+	}
+	if got, want := string(unsortedErr.PreviousKey), "aa"; got != want {
+		t.Errorf("err.PreviousKey = %#v; want %#v", got, want)
+	}
+	if got, want := unsortedErr.Offset, int64(8); got != want {
+		t.Errorf("err.Offset = %#v; want %#v", got, want)
+	}
+	if got, want := unsortedErr.PreviousOffset, int64(1); got != want {
+		t.Errorf("err.PreviousOffset = %#v; want %#v", got, want)
+	}
+
+	// Without strict mode, the same input is accepted.
+	d = bencode.NewDecoder(bytes.NewBuffer([]byte(input)))
+	if _, err := d.Decode(); err != nil {
+		t.Errorf("Decode(%#v) returned error without strict mode: %s", input, err)
+	}
+}
+
+func TestDecoder_Decode_StrictTrailingData(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.Strict = true
+
+	tests := map[string]int64{
+		"i1ei2e": 3,
+		"le1:a":  2,
+		"dee":    2,
+	}
+
+	for input, wantOffset := range tests {
+		d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+		_, err := d.Decode()
+
+		var trailingErr *bencode.ErrTrailingData
+		if !errors.As(err, &trailingErr) {
+			t.Errorf("Decode(%#v) = %#v; want *bencode.ErrTrailingData", input, err)
+
+			continue
+		}
+
+		if got, want := trailingErr.Offset, wantOffset; got != want {
+			t.Errorf("Decode(%#v).Offset = %#v; want %#v", input, got, want)
+		}
+	}
+}
+
+func TestDecoder_Decode_NonStringKey(t *testing.T) {
+	input := "d4:infod5:filesld4:pathl1:aei1ei2eeeee"
+
+	d := bencode.NewDecoder(bytes.NewBuffer([]byte(input)))
+
+	_, err := d.Decode()
+
+	var keyErr *bencode.ErrNonStringKey
+	if !errors.As(err, &keyErr) {
+		t.Fatalf("Decode(%#v) = %#v; want *bencode.ErrNonStringKey", input, err)
+	}
+
+	if got, want := string(keyErr.Raw), "i1e"; got != want {
+		t.Errorf("err.Raw = %#v; want %#v", got, want)
+	}
+	if got, want := keyErr.Offset, int64(28); got != want {
+		t.Errorf("err.Offset = %#v; want %#v", got, want)
+	}
+	if got, want := keyErr.DictionaryOffset, int64(16); got != want {
+		t.Errorf("err.DictionaryOffset = %#v; want %#v", got, want)
+	}
+	if got, want := keyErr.Path.String(), "/info/files/0"; got != want {
+		t.Errorf("err.Path.String() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDecoder_Decode_UnexpectedEnd(t *testing.T) {
+	tests := []struct {
+		Input           string
+		Offset          int64
+		ContainerOffset int64
+		Key             string
+		Path            string
+		IsEOF           bool
+	}{
+		{Input: "e", Offset: 0, ContainerOffset: -1},
+		{Input: "d1:ae", Offset: 4, ContainerOffset: 0, Key: "a"},
+		{Input: "d1:ali1ed1:be", Offset: 12, ContainerOffset: 8, Key: "b", Path: "/a/1"},
+		{Input: "li1e", Offset: 4, ContainerOffset: 0, IsEOF: true},
+		{Input: "d1:ald1:x", Offset: 9, ContainerOffset: 5, Key: "x", Path: "/a/0", IsEOF: true},
+	}
+
+	for _, tc := range tests {
+		d := bencode.NewDecoder(bytes.NewBuffer([]byte(tc.Input)))
+
+		_, err := d.Decode()
+
+		var endErr *bencode.ErrUnexpectedEnd
+		if !errors.As(err, &endErr) {
+			t.Errorf("Decode(%#v) = %#v; want *bencode.ErrUnexpectedEnd", tc.Input, err)
+
+			continue
+		}
+
+		if got, want := endErr.Offset, tc.Offset; got != want {
+			t.Errorf("Decode(%#v): err.Offset = %#v; want %#v", tc.Input, got, want)
+		}
+		if got, want := endErr.ContainerOffset, tc.ContainerOffset; got != want {
+			t.Errorf("Decode(%#v): err.ContainerOffset = %#v; want %#v", tc.Input, got, want)
+		}
+		if got, want := string(endErr.Key), tc.Key; got != want {
+			t.Errorf("Decode(%#v): err.Key = %#v; want %#v", tc.Input, got, want)
+		}
+		if got, want := endErr.Path.String(), tc.Path; got != want {
+			t.Errorf("Decode(%#v): err.Path.String() = %#v; want %#v", tc.Input, got, want)
+		}
+		if got, want := errors.Is(err, io.ErrUnexpectedEOF), tc.IsEOF; got != want {
+			t.Errorf("Decode(%#v): errors.Is(err, io.ErrUnexpectedEOF) = %#v; want %#v", tc.Input, got, want)
+		}
+	}
+}
+
+func TestDecoder_Decode_StrictKeyErrorPath(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.Strict = true
+
+	input := "d4:infold1:bi1e1:ai2eeee"
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+	_, err := d.Decode()
+
+	var unsortedErr *bencode.ErrUnsortedKey
+	if !errors.As(err, &unsortedErr) {
+		t.Fatalf("Decode(%#v) = %#v; want *bencode.ErrUnsortedKey", input, err)
+	}
+
+	if got, want := unsortedErr.Path.String(), "/info/0"; got != want {
+		t.Errorf("err.Path.String() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDecoder_Decode_KeyErrorMessage(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.Strict = true
+
+	testCases := []struct {
+		Input           string
+		ExpectedMessage string
+	}{
+		{
+			Input:           "d1:ai1e1:ai2ee",
+			ExpectedMessage: `duplicate dictionary key "a" at offset 7; previously found at offset 1`,
+		},
+		{
+			Input:           "d1:bi1e1:ai2ee",
+			ExpectedMessage: `dictionary key "a" at offset 7 is not sorted after key "b" at offset 1`,
+		},
+		{
+			Input:           "di1ei2ee",
+			ExpectedMessage: `non-string dictionary key "i1e" at offset 1, in dictionary starting at offset 0`,
+		},
+		{
+			Input:           "d1:ae",
+			ExpectedMessage: `unexpected end at offset 4; missing value for key "a" at offset 1`,
+		},
+		{
+			Input:           "d1:ad1:bi1e1:ce",
+			ExpectedMessage: `unexpected end at offset 14 in "/a"; missing value for key "c" at offset 11`,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(tc.Input)), options).Decode()
+		if err == nil {
+			t.Errorf("Decode(%#v) returned nil error", tc.Input)
+
+			continue
+		}
+
+		if got, want := err.Error(), tc.ExpectedMessage; got != want {
+			t.Errorf("Decode(%#v): err.Error() = %#v; want %#v", tc.Input, got, want)
+		}
+	}
+}
+
+func TestDecoder_Decode_MaxDepth(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxDepth = 3
+
+	if _, err := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte("ld1:aleee")), options).Decode(); err != nil {
+		t.Errorf("Decode() returned error at maximum depth: %s", err)
+	}
+
+	input := "ld1:alleee"
+	_, err := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options).Decode()
+
+	var depthErr *bencode.ErrMaxDepthExceeded
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("Decode(%#v) = %#v; want *bencode.ErrMaxDepthExceeded", input, err)
+	}
+
+	if got, want := depthErr.Offset, int64(6); got != want {
+		t.Errorf("err.Offset = %#v; want %#v", got, want)
+	}
+	if got, want := depthErr.MaxDepth, 3; got != want {
+		t.Errorf("err.MaxDepth = %#v; want %#v", got, want)
+	}
+}
+
+func TestDecoder_Token_MaxDepth(t *testing.T) {
+	input := bytes.Repeat([]byte{'l'}, 1000000)
+
+	d := bencode.NewDecoder(bytes.NewBuffer(input))
+
+	for {
+		_, err := d.Token()
+		if err == nil {
+			continue
+		}
+
+		var depthErr *bencode.ErrMaxDepthExceeded
+		if !errors.As(err, &depthErr) {
+			t.Fatalf("d.Token() = %#v; want *bencode.ErrMaxDepthExceeded", err)
+		}
+
+		if got, want := depthErr.Offset, int64(bencode.DefaultDecoderOptions.MaxDepth); got != want {
+			t.Errorf("err.Offset = %#v; want %#v", got, want)
+		}
+
+		break
+	}
+}
+
+func TestDecoder_Decode_Budgets(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Options func(o *bencode.DecoderOptions)
+		Budget  string
+		Offset  int64
+	}{
+		{
+			Input:   "l4:spam4:eggse",
+			Options: func(o *bencode.DecoderOptions) { o.MaxTotalBytes = 10 },
+			Budget:  "MaxTotalBytes",
+			Offset:  7,
+		},
+		{
+			Input:   "li1ei2ei3ee",
+			Options: func(o *bencode.DecoderOptions) { o.MaxTotalBytes = 10 },
+			Budget:  "MaxTotalBytes",
+			Offset:  10,
+		},
+		{
+			Input:   "l4:spam4:eggse",
+			Options: func(o *bencode.DecoderOptions) { o.MaxTotalStringBytes = 7 },
+			Budget:  "MaxTotalStringBytes",
+			Offset:  7,
+		},
+		{
+			Input:   "li1ei2ei3ee",
+			Options: func(o *bencode.DecoderOptions) { o.MaxElements = 2 },
+			Budget:  "MaxElements",
+			Offset:  7,
+		},
+		{
+			Input:   "d1:ai1e1:bi2e1:ci3ee",
+			Options: func(o *bencode.DecoderOptions) { o.MaxElements = 2 },
+			Budget:  "MaxElements",
+			Offset:  13,
+		},
+	}
+
+	for _, tc := range tests {
+		options := bencode.DefaultDecoderOptions
+		tc.Options(&options)
+
+		_, err := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(tc.Input)), options).Decode()
+
+		var budgetErr *bencode.ErrBudgetExceeded
+		if !errors.As(err, &budgetErr) {
+			t.Errorf("Decode(%#v) = %#v; want *bencode.ErrBudgetExceeded", tc.Input, err)
+
+			continue
+		}
+
+		if got, want := budgetErr.Budget, tc.Budget; got != want {
+			t.Errorf("Decode(%#v): err.Budget = %#v; want %#v", tc.Input, got, want)
+		}
+		if got, want := budgetErr.Offset, tc.Offset; got != want {
+			t.Errorf("Decode(%#v): err.Offset = %#v; want %#v", tc.Input, got, want)
+		}
+	}
+}
+
+func TestDecoder_Decode_BudgetsPerValue(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxTotalBytes = 6
+	options.MaxTotalStringBytes = 4
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte("4:spam4:eggs")), options)
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.Decode(); err != nil {
+			t.Errorf("values[%d]: Decode() returned error: %s", i, err)
+		}
+	}
+}
+
+// unbufferedReader hides any method of the underlying reader other than
+// `Read`, like `os.File` or `net.Conn` would.
+type unbufferedReader struct {
+	r io.Reader
+}
+
+func (r *unbufferedReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func benchmarkInput() []byte {
+	var files bencode.List
+	for i := 0; i < 1000; i++ {
+		files = append(files, makeDictionary(map[string]bencode.Value{
+			"length": bencode.Integer(i * 1024),
+			"path":   bencode.List{bencode.String("directory"), bencode.String("file name.ext")},
+		}))
+	}
+
+	return makeDictionary(map[string]bencode.Value{
+		"announce": bencode.String("http://tracker.example.com/announce"),
+		"info": makeDictionary(map[string]bencode.Value{
+			"files":        files,
+			"name":         bencode.String("example"),
+			"piece length": bencode.Integer(262144),
+			"pieces":       bencode.String(bytes.Repeat([]byte{0xaa}, 20*1000)),
+		}),
+	}).Bencode()
+}
+
+func BenchmarkDecoder_Decode(b *testing.B) {
+	input := benchmarkInput()
+
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		d := bencode.NewDecoder(&unbufferedReader{r: bytes.NewReader(input)})
+
+		if _, err := d.Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder_Token(b *testing.B) {
+	input := benchmarkInput()
+
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		d := bencode.NewDecoder(&unbufferedReader{r: bytes.NewReader(input)})
+
+		for {
+			_, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestDecoder_Buffered(t *testing.T) {
+	r := &unbufferedReader{r: bytes.NewReader([]byte("d1:ai1ee\nrest of the stream"))}
+
+	d := bencode.NewDecoder(r)
+
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+
+	rest, err := io.ReadAll(io.MultiReader(d.Buffered(), r))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(rest), "\nrest of the stream"; got != want {
+		t.Errorf("remaining data = %#v; want %#v", got, want)
+	}
+}
+
+func TestNewDecoder_BufioReader(t *testing.T) {
+	// Smaller than the default buffer size.
+	r := bufio.NewReaderSize(bytes.NewReader([]byte("d1:ai1ee\nrest of the stream")), 16)
+
+	d := bencode.NewDecoder(r)
+
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := d.Buffered().(*bytes.Reader).Len(), r.Buffered(); got != want {
+		t.Errorf("d.Buffered() has %d bytes; want %d", got, want)
+	}
+
+	// The decoder reads from `r` directly, so `r` holds the rest of the data.
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(rest), "\nrest of the stream"; got != want {
+		t.Errorf("remaining data = %#v; want %#v", got, want)
+	}
+}
+
+func TestNewBytesDecoder_ZeroCopy(t *testing.T) {
+	input := []byte("d4:spami-12ee")
+
+	d := bencode.NewBytesDecoder(input)
+
+	var tokens []bencode.Token
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if got, want := len(tokens), 4; got != want {
+		t.Fatalf("len(tokens) = %#v; want %#v", got, want)
+	}
+
+	for i, token := range tokens {
+		raw := token.Raw()
+		if got, want := &raw[0], &input[token.Offset()]; got != want {
+			t.Errorf("tokens[%d].Raw() does not share memory with the input", i)
+		}
+	}
+
+	str := tokens[1].(*bencode.TokenString)
+	if got, want := string(str.Value), "spam"; got != want {
+		t.Errorf("string(str.Value) = %#v; want %#v", got, want)
+	}
+	if got, want := &str.Value[0], &input[3]; got != want {
+		t.Errorf("str.Value does not share memory with the input")
+	}
+	if got, want := string(tokens[2].Raw()), "i-12e"; got != want {
+		t.Errorf("string(tokens[2].Raw()) = %#v; want %#v", got, want)
+	}
+}
+
+func TestNewBytesDecoder_CopyStrings(t *testing.T) {
+	input := []byte("l4:spame")
+
+	options := bencode.DefaultDecoderOptions
+	options.CopyStrings = true
+
+	value, err := bencode.NewBytesDecoderWithOptions(input, options).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copy(input, "xxxxxxxx")
+
+	if got, want := string(value.([]interface{})[0].([]byte)), "spam"; got != want {
+		t.Errorf("value[0] = %#v; want %#v", got, want)
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	input := []byte("d1:ali1e1:bee")
+
+	value, err := bencode.DecodeBytes(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := value.(map[string]interface{})["a"].([]interface{})
+	if got, want := list[0], int64(1); got != want {
+		t.Errorf("value[\"a\"][0] = %#v; want %#v", got, want)
+	}
+	if got, want := string(list[1].([]byte)), "b"; got != want {
+		t.Errorf("value[\"a\"][1] = %#v; want %#v", got, want)
+	}
+
+	d := bencode.NewBytesDecoder([]byte("i1eextra"))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+
+	rest, _ := io.ReadAll(d.Buffered())
+	if got, want := string(rest), "extra"; got != want {
+		t.Errorf("d.Buffered() = %#v; want %#v", got, want)
+	}
+
+	if _, err := bencode.DecodeBytes([]byte("10:short")); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("DecodeBytes(truncated) = %#v; want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestUnmarshal_DoesNotAliasInput(t *testing.T) {
+	input := []byte("l4:spame")
+
+	var value interface{}
+	if err := bencode.Unmarshal(input, &value); err != nil {
+		t.Fatal(err)
+	}
+
+	copy(input, "xxxxxxxx")
+
+	if got, want := string(value.([]interface{})[0].([]byte)), "spam"; got != want {
+		t.Errorf("value[0] = %#v; want %#v", got, want)
+	}
+}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	input := benchmarkInput()
+
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := bencode.DecodeBytes(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoder_PeekKind(t *testing.T) {
+	input := "d1:ali1ee1:bi2ee"
+
+	d := bencode.NewDecoder(bytes.NewBuffer([]byte(input)))
+
+	wantKinds := []bencode.Kind{
+		bencode.KindDictionary,
+		bencode.KindString,
+		bencode.KindList,
+		bencode.KindInteger,
+		bencode.KindEnd,
+		bencode.KindString,
+		bencode.KindInteger,
+		bencode.KindEnd,
+	}
+
+	for i, want := range wantKinds {
+		got, err := d.PeekKind()
+		if err != nil {
+			t.Fatalf("tokens[%d]: d.PeekKind() returned error: %s", i, err)
+		}
+
+		if got != want {
+			t.Errorf("tokens[%d]: d.PeekKind() = %s; want %s", i, got, want)
+		}
+
+		// Peeking twice doesn't consume anything.
+		if again, _ := d.PeekKind(); again != got {
+			t.Errorf("tokens[%d]: second d.PeekKind() = %s; want %s", i, again, got)
+		}
+
+		if _, err := d.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := d.PeekKind(); err != io.EOF {
+		t.Errorf("d.PeekKind() at end = %#v; want io.EOF", err)
+	}
+
+	kind, err := bencode.NewDecoder(bytes.NewBuffer([]byte("x"))).PeekKind()
+	if kind != bencode.KindInvalid || err != nil {
+		t.Errorf("d.PeekKind() = %s, %#v; want %s, nil", kind, err, bencode.KindInvalid)
+	}
+}
+
+func TestDecoder_More(t *testing.T) {
+	input := []byte("li1ei2ei3ee")
+
+	for _, d := range []*bencode.Decoder{bencode.NewDecoder(bytes.NewBuffer(input)), bencode.NewBytesDecoder(input)} {
+		if _, err := d.Token(); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []int64
+		for d.More() {
+			var n int64
+			if err := d.DecodeInto(&n); err != nil {
+				t.Fatal(err)
+			}
+
+			got = append(got, n)
+		}
+
+		if got, want := len(got), 3; got != want {
+			t.Errorf("len(got) = %#v; want %#v", got, want)
+		}
+
+		if _, err := d.Token(); err != nil {
+			t.Fatal(err)
+		}
+
+		if d.More() {
+			t.Errorf("d.More() = true at the end of the input")
+		}
+	}
+}
+
+func TestDecoder_Skip(t *testing.T) {
+	input := "d1:ad1:xli1eee1:bi2ee"
+
+	d := bencode.NewDecoder(bytes.NewBuffer([]byte(input)))
+
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]int64{}
+	for d.More() {
+		var key string
+		if err := d.DecodeInto(&key); err != nil {
+			t.Fatal(err)
+		}
+
+		if key != "b" {
+			if err := d.Skip(); err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		}
+
+		var value int64
+		if err := d.DecodeInto(&value); err != nil {
+			t.Fatal(err)
+		}
+
+		got[key] = value
+	}
+
+	if got, want := len(got), 1; got != want {
+		t.Errorf("len(got) = %#v; want %#v", got, want)
+	}
+	if got, want := got["b"], int64(2); got != want {
+		t.Errorf("got[\"b\"] = %#v; want %#v", got, want)
+	}
+
+	if err := d.Skip(); err == nil {
+		t.Errorf("d.Skip() before end token returned nil error")
+	}
+
+	if _, ok := mustToken(t, d).(*bencode.TokenEnd); !ok {
+		t.Errorf("d.Skip() consumed the end token")
+	}
+}
+
+func mustToken(t *testing.T, d *bencode.Decoder) bencode.Token {
+	t.Helper()
+
+	token, err := d.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestDecoder_Decode_StrictNested(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.Strict = true
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte("li1ei2ee")), options)
+
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.Decode(); err != nil {
+			t.Fatalf("values[%d]: d.Decode() returned error: %s", i, err)
+		}
+	}
+
+	if _, ok := mustToken(t, d).(*bencode.TokenEnd); !ok {
+		t.Errorf("expected end token after nested values")
+	}
+}
+
+func TestDecoder_Decode_SkipLongStrings(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxStringLength = 6
+	options.SkipLongStrings = true
+
+	input := "d4:name1:x6:pieces10:0123456789e"
+
+	for i, d := range []*bencode.Decoder{
+		bencode.NewDecoderWithOptions(&unbufferedReader{bytes.NewBuffer([]byte(input))}, options),
+		bencode.NewBytesDecoderWithOptions([]byte(input), options),
+	} {
+		value, err := d.Decode()
+		if err != nil {
+			t.Fatalf("decoders[%d]: d.Decode() returned error: %s", i, err)
+		}
+
+		dict := value.(map[string]interface{})
+
+		skipped, ok := dict["pieces"].(*bencode.TokenSkippedString)
+		if !ok {
+			t.Fatalf("decoders[%d]: dict[\"pieces\"] = %#v; want *bencode.TokenSkippedString", i, dict["pieces"])
+		}
+
+		if got, want := skipped.Offset(), int64(18); got != want {
+			t.Errorf("decoders[%d]: skipped.Offset() = %#v; want %#v", i, got, want)
+		}
+		if got, want := skipped.Length, int64(10); got != want {
+			t.Errorf("decoders[%d]: skipped.Length = %#v; want %#v", i, got, want)
+		}
+		if got, want := string(skipped.Raw()), "10:"; got != want {
+			t.Errorf("decoders[%d]: string(skipped.Raw()) = %#v; want %#v", i, got, want)
+		}
+	}
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte("d7:pieces_i0ee")), options)
+
+	_, err := d.Decode()
+
+	var errStringTooLong *bencode.ErrStringTooLong
+	if !errors.As(err, &errStringTooLong) {
+		t.Errorf("d.Decode() with a long key returned %#v; want *bencode.ErrStringTooLong", err)
+	}
+}
+
+func TestDecoder_DecodeInto_SkipLongStrings(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxStringLength = 6
+	options.SkipLongStrings = true
+
+	input := "d4:name1:x6:pieces10:0123456789e"
+
+	var torrent struct {
+		Name   string                      `bencode:"name"`
+		Pieces *bencode.TokenSkippedString `bencode:"pieces"`
+	}
+
+	d := bencode.NewBytesDecoderWithOptions([]byte(input), options)
+	if err := d.DecodeInto(&torrent); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := torrent.Name, "x"; got != want {
+		t.Errorf("torrent.Name = %#v; want %#v", got, want)
+	}
+	if torrent.Pieces == nil {
+		t.Fatalf("torrent.Pieces = nil; want a skipped string")
+	}
+	if got, want := torrent.Pieces.Length, int64(10); got != want {
+		t.Errorf("torrent.Pieces.Length = %#v; want %#v", got, want)
+	}
+	if got, want := torrent.Pieces.Offset(), int64(18); got != want {
+		t.Errorf("torrent.Pieces.Offset() = %#v; want %#v", got, want)
+	}
+
+	// A string target can't tell a skipped string from an empty one.
+	var bytesTorrent struct {
+		Name   string `bencode:"name"`
+		Pieces []byte `bencode:"pieces"`
+	}
+
+	d = bencode.NewBytesDecoderWithOptions([]byte(input), options)
+
+	var bytesErr *bencode.ErrStringTooLong
+	if err := d.DecodeInto(&bytesTorrent); !errors.As(err, &bytesErr) {
+		t.Errorf("d.DecodeInto(&bytesTorrent) returned %#v; want *bencode.ErrStringTooLong", err)
+	}
+
+	var anyTorrent map[string]interface{}
+
+	d = bencode.NewBytesDecoderWithOptions([]byte(input), options)
+	if err := d.DecodeInto(&anyTorrent); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := anyTorrent["pieces"].(*bencode.TokenSkippedString); !ok {
+		t.Errorf("anyTorrent[\"pieces\"] = %#v; want *bencode.TokenSkippedString", anyTorrent["pieces"])
+	}
+
+	d = bencode.NewBytesDecoderWithOptions([]byte(input), options)
+
+	var raw bencode.RawMessage
+	err := d.DecodeInto(&raw)
+
+	var errStringTooLong *bencode.ErrStringTooLong
+	if !errors.As(err, &errStringTooLong) {
+		t.Fatalf("d.DecodeInto(&raw) returned %#v; want *bencode.ErrStringTooLong", err)
+	}
+}
+
+func TestDecoder_SkipValue(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxStringLength = 4
+
+	input := "l10:0123456789i1ee"
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := d.Token()
+
+		var errStringTooLong *bencode.ErrStringTooLong
+		if !errors.As(err, &errStringTooLong) {
+			t.Fatalf("attempts[%d]: d.Token() returned %#v; want *bencode.ErrStringTooLong", i, err)
+		}
+
+		if got, want := errStringTooLong.NextTokenOffset, int64(14); got != want {
+			t.Errorf("errStringTooLong.NextTokenOffset = %#v; want %#v", got, want)
+		}
+	}
+
+	if err := d.SkipValue(); err != nil {
+		t.Fatal(err)
+	}
+
+	token := mustToken(t, d)
+	if got, want := token.Offset(), int64(14); got != want {
+		t.Errorf("token.Offset() = %#v; want %#v", got, want)
+	}
+
+	if err := d.SkipValue(); err == nil {
+		t.Errorf("d.SkipValue() before end token returned nil error")
+	}
+
+	if _, ok := mustToken(t, d).(*bencode.TokenEnd); !ok {
+		t.Errorf("expected end token")
+	}
+}
+
+func TestDecoder_SkipValue_Key(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxStringLength = 4
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte("d10:0123456789i1ee")), options)
+
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Token(); err == nil {
+		t.Fatalf("d.Token() with a long key returned nil error")
+	}
+
+	if err := d.SkipValue(); err == nil {
+		t.Errorf("d.SkipValue() on a dictionary key returned nil error")
+	}
+}
+
+func TestDecoder_PeekKind_PendingSkip(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxStringLength = 3
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte("l10:eeeeeeeeeei1ee")), options)
+
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := d.Token()
+
+	var errStringTooLong *bencode.ErrStringTooLong
+	if !errors.As(err, &errStringTooLong) {
+		t.Fatalf("d.Token() returned %#v; want *bencode.ErrStringTooLong", err)
+	}
+
+	if kind, got := d.PeekKind(); got != err {
+		t.Errorf("d.PeekKind() = %#v, %#v; want %#v", kind, got, err)
+	}
+
+	if more := d.More(); more != true {
+		t.Errorf("d.More() = %#v; want %#v", more, true)
+	}
+
+	if err := d.SkipValue(); err != nil {
+		t.Fatal(err)
+	}
+
+	kind, err := d.PeekKind()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := kind, bencode.KindInteger; got != want {
+		t.Errorf("d.PeekKind() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDecoder_Token_StringReader(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.MaxStringLength = 6
+	options.StringReaderThreshold = 8
+
+	input := "d6:pieces10:01234567896:piecesl3:abc8:abcdefgh12:0123456789abee"
+
+	for i, d := range []*bencode.Decoder{
+		bencode.NewDecoderWithOptions(&unbufferedReader{bytes.NewBuffer([]byte(input))}, options),
+		bencode.NewBytesDecoderWithOptions([]byte(input), options),
+	} {
+		if _, err := d.Token(); err != nil {
+			t.Fatalf("decoders[%d]: %s", i, err)
+		}
+
+		if _, ok := mustToken(t, d).(*bencode.TokenString); !ok {
+			t.Errorf("decoders[%d]: dictionary key was not returned as *bencode.TokenString", i)
+		}
+
+		token := mustToken(t, d)
+
+		sr, ok := token.(*bencode.TokenStringReader)
+		if !ok {
+			t.Fatalf("decoders[%d]: d.Token() = %#v; want *bencode.TokenStringReader", i, token)
+		}
+
+		if got, want := sr.Offset(), int64(9); got != want {
+			t.Errorf("decoders[%d]: sr.Offset() = %#v; want %#v", i, got, want)
+		}
+		if got, want := sr.Length, int64(10); got != want {
+			t.Errorf("decoders[%d]: sr.Length = %#v; want %#v", i, got, want)
+		}
+		if got, want := string(sr.Raw()), "10:"; got != want {
+			t.Errorf("decoders[%d]: string(sr.Raw()) = %#v; want %#v", i, got, want)
+		}
+
+		payload, err := io.ReadAll(sr.Reader)
+		if err != nil {
+			t.Fatalf("decoders[%d]: %s", i, err)
+		}
+
+		if got, want := string(payload), "0123456789"; got != want {
+			t.Errorf("decoders[%d]: payload = %#v; want %#v", i, got, want)
+		}
+
+		mustToken(t, d)
+		mustToken(t, d)
+
+		if got, want := string(mustToken(t, d).(*bencode.TokenString).Value), "abc"; got != want {
+			t.Errorf("decoders[%d]: short string = %#v; want %#v", i, got, want)
+		}
+
+		// Only read part of the payload; the rest is discarded by the next
+		// call to `Token`.
+		sr = mustToken(t, d).(*bencode.TokenStringReader)
+
+		b := make([]byte, 3)
+		if _, err := io.ReadFull(sr.Reader, b); err != nil {
+			t.Fatalf("decoders[%d]: %s", i, err)
+		}
+
+		if got, want := string(b), "abc"; got != want {
+			t.Errorf("decoders[%d]: b = %#v; want %#v", i, got, want)
+		}
+
+		// Strings above the threshold are read into memory by `Decode`, but
+		// are still limited by `MaxStringLength`.
+		_, err = d.Decode()
+
+		var errStringTooLong *bencode.ErrStringTooLong
+		if !errors.As(err, &errStringTooLong) {
+			t.Errorf("decoders[%d]: d.Decode() returned %#v; want *bencode.ErrStringTooLong", i, err)
+		}
+
+		if _, err := sr.Reader.Read(b); err == nil {
+			t.Errorf("decoders[%d]: sr.Reader.Read() after the next token returned nil error", i)
+		}
+
+		if _, ok := mustToken(t, d).(*bencode.TokenEnd); !ok {
+			t.Errorf("decoders[%d]: expected end token", i)
+		}
+		if _, ok := mustToken(t, d).(*bencode.TokenEnd); !ok {
+			t.Errorf("decoders[%d]: expected end token", i)
+		}
+	}
+}
+
+func TestDecoder_Decode_StringReader(t *testing.T) {
+	options := bencode.DefaultDecoderOptions
+	options.StringReaderThreshold = 8
+
+	input := "l10:0123456789e"
+
+	var got []string
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+	if err := d.DecodeInto(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0] != "0123456789" {
+		t.Errorf("got = %#v; want %#v", got, []string{"0123456789"})
+	}
+
+	d = bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+	raw, err := d.DecodeRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(raw), input; got != want {
+		t.Errorf("d.DecodeRaw() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDecoder_Path(t *testing.T) {
+	input := "d4:infod5:filesld4:pathl1:aeeeee"
+
+	want := []string{
+		"",
+		"/info",
+		"/info",
+		"/info/files",
+		"/info/files",
+		"/info/files/0",
+		"/info/files/0/path",
+		"/info/files/0/path",
+		"/info/files/0/path/0",
+		"/info/files/0/path",
+		"/info/files/0",
+		"/info/files",
+		"/info",
+		"",
+	}
+
+	d := bencode.NewDecoder(bytes.NewBuffer([]byte(input)))
+
+	for i, want := range want {
+		mustToken(t, d)
+
+		if got := d.Path().String(); got != want {
+			t.Errorf("tokens[%d]: d.Path().String() = %#v; want %#v", i, got, want)
+		}
+	}
+}
+
+func TestDecoder_Token_ErrorPath(t *testing.T) {
+	testCases := []struct {
+		Input        string
+		ExpectedPath string
+	}{
+		{
+			Input:        "d4:infod5:filesli1ei2ex",
+			ExpectedPath: "/info/files/2",
+		},
+		{
+			Input:        "d4:infod4:namei1xe",
+			ExpectedPath: "/info/name",
+		},
+		{
+			Input:        "d4:infod4:name1:xx",
+			ExpectedPath: "/info",
+		},
+		{
+			Input:        "x",
+			ExpectedPath: "",
+		},
+	}
+
+	for i, tc := range testCases {
+		d := bencode.NewDecoder(bytes.NewBuffer([]byte(tc.Input)))
+
+		_, err := d.Decode()
+
+		var errInvalidToken *bencode.ErrInvalidToken
+		var errUnexpectedByte *bencode.ErrUnexpectedByte
+
+		var path bencode.Path
+		if errors.As(err, &errInvalidToken) {
+			path = errInvalidToken.Path
+		} else if errors.As(err, &errUnexpectedByte) {
+			path = errUnexpectedByte.Path
+		} else {
+			t.Fatalf("testCases[%d]: d.Decode() returned %#v; want a syntax error", i, err)
+		}
+
+		if got, want := path.String(), tc.ExpectedPath; got != want {
+			t.Errorf("testCases[%d]: path.String() = %#v; want %#v", i, got, want)
+		}
+	}
+}
+
+func TestDecoder_Token_SyntaxError(t *testing.T) {
+	testCases := []struct {
+		Input           string
+		ExpectedOffset  int64
+		ExpectedGot     string
+		ExpectedExcerpt string
+		IsEOF           bool
+	}{
+		{
+			Input:           "li1ei2xe",
+			ExpectedOffset:  6,
+			ExpectedGot:     "x",
+			ExpectedExcerpt: "00000000  6c 69 31 65 69 32 78 65  |li1ei2xe|",
+		},
+		{
+			Input:           "li1ei2ei3ei4ei5xe",
+			ExpectedOffset:  15,
+			ExpectedGot:     "x",
+			ExpectedExcerpt: "00000007  69 33 65 69 34 65 69 35 78 65  |i3ei4ei5xe|",
+		},
+		{
+			Input:           "l3x:abce",
+			ExpectedOffset:  2,
+			ExpectedGot:     "x",
+			ExpectedExcerpt: "00000000  6c 33 78 3a 61 62 63 65  |l3x:abce|",
+		},
+		{
+			Input:           "i03e",
+			ExpectedOffset:  0,
+			ExpectedGot:     "i03e",
+			ExpectedExcerpt: "00000000  69 30 33 65  |i03e|",
+		},
+		{
+			Input:           "lxe",
+			ExpectedOffset:  1,
+			ExpectedGot:     "x",
+			ExpectedExcerpt: "00000000  6c 78 65  |lxe|",
+		},
+		{
+			Input:           "li12",
+			ExpectedOffset:  4,
+			ExpectedGot:     "",
+			ExpectedExcerpt: "00000000  6c 69 31 32  |li12|",
+			IsEOF:           true,
+		},
+	}
+
+	for i, tc := range testCases {
+		d := bencode.NewDecoder(&unbufferedReader{bytes.NewBuffer([]byte(tc.Input))})
+
+		_, err := d.Decode()
+
+		var syntaxErr *bencode.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("testCases[%d]: d.Decode() returned %#v; want *bencode.SyntaxError", i, err)
+
+			continue
+		}
+
+		if got, want := syntaxErr.Offset, tc.ExpectedOffset; got != want {
+			t.Errorf("testCases[%d]: syntaxErr.Offset = %#v; want %#v", i, got, want)
+		}
+		if got, want := string(syntaxErr.Got), tc.ExpectedGot; got != want {
+			t.Errorf("testCases[%d]: string(syntaxErr.Got) = %#v; want %#v", i, got, want)
+		}
+		if got, want := syntaxErr.Excerpt, tc.ExpectedExcerpt; got != want {
+			t.Errorf("testCases[%d]: syntaxErr.Excerpt = %#v; want %#v", i, got, want)
+		}
+		if got, want := errors.Is(err, io.ErrUnexpectedEOF), tc.IsEOF; got != want {
+			t.Errorf("testCases[%d]: errors.Is(err, io.ErrUnexpectedEOF) = %#v; want %#v", i, got, want)
+		}
+
+		// Bytes mode must report the same error.
+		_, err = bencode.DecodeBytes([]byte(tc.Input))
+
+		var bytesSyntaxErr *bencode.SyntaxError
+		if !errors.As(err, &bytesSyntaxErr) {
+			t.Errorf("testCases[%d]: DecodeBytes() returned %#v; want *bencode.SyntaxError", i, err)
+
+			continue
+		}
+
+		if got, want := bytesSyntaxErr.Offset, tc.ExpectedOffset; got != want {
+			t.Errorf("testCases[%d]: bytesSyntaxErr.Offset = %#v; want %#v", i, got, want)
+		}
+		if got, want := bytesSyntaxErr.Excerpt, tc.ExpectedExcerpt; got != want {
+			t.Errorf("testCases[%d]: bytesSyntaxErr.Excerpt = %#v; want %#v", i, got, want)
+		}
+	}
+}
+
+func TestDecoder_Decode_SyntaxError_LongToken(t *testing.T) {
+	input := []byte("i" + strings.Repeat("9", 65000) + "e")
+
+	decoders := map[string]*bencode.Decoder{
+		"reader": bencode.NewDecoder(bytes.NewBuffer(input)),
+		"bytes":  bencode.NewBytesDecoder(input),
+	}
+
+	for mode, d := range decoders {
+		_, err := d.Decode()
+
+		var syntaxErr *bencode.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: d.Decode() returned %#v; want *bencode.SyntaxError", mode, err)
+
+			continue
+		}
+
+		if got, want := len(syntaxErr.Got), 32; got != want {
+			t.Errorf("%s: len(syntaxErr.Got) = %#v; want %#v", mode, got, want)
+		}
+		if got := len(err.Error()); got > 200 {
+			t.Errorf("%s: len(err.Error()) = %#v; want at most 200", mode, got)
+		}
+		if got := len(syntaxErr.Excerpt); got > 200 {
+			t.Errorf("%s: len(syntaxErr.Excerpt) = %#v; want at most 200", mode, got)
+		}
+	}
+}
+
+func TestDecoder_Decode_SyntaxError_TruncatedString(t *testing.T) {
+	input := []byte("l1:a10:short")
+
+	skipOptions := bencode.DefaultDecoderOptions
+	skipOptions.MaxStringLength = 4
+	skipOptions.SkipLongStrings = true
+
+	readerOptions := bencode.DefaultDecoderOptions
+	readerOptions.StringReaderThreshold = 8
+
+	testCases := []struct {
+		Name    string
+		Options bencode.DecoderOptions
+	}{
+		{
+			Name:    "default",
+			Options: bencode.DefaultDecoderOptions,
+		},
+		{
+			Name:    "SkipLongStrings",
+			Options: skipOptions,
+		},
+		{
+			Name:    "StringReaderThreshold",
+			Options: readerOptions,
+		},
+	}
+
+	for _, tc := range testCases {
+		decoders := map[string]*bencode.Decoder{
+			"reader": bencode.NewDecoderWithOptions(&unbufferedReader{bytes.NewBuffer(input)}, tc.Options),
+			"bytes":  bencode.NewBytesDecoderWithOptions(input, tc.Options),
+		}
+
+		for mode, d := range decoders {
+			_, err := d.Decode()
+
+			var syntaxErr *bencode.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("%s, %s: d.Decode() returned %#v; want *bencode.SyntaxError", tc.Name, mode, err)
+
+				continue
+			}
+
+			if got, want := syntaxErr.Offset, int64(len(input)); got != want {
+				t.Errorf("%s, %s: syntaxErr.Offset = %#v; want %#v", tc.Name, mode, got, want)
+			}
+			if got, want := syntaxErr.Path.String(), "/1"; got != want {
+				t.Errorf("%s, %s: syntaxErr.Path.String() = %#v; want %#v", tc.Name, mode, got, want)
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("%s, %s: errors.Is(err, io.ErrUnexpectedEOF) = false", tc.Name, mode)
+			}
+		}
+	}
+}
+
+func TestDecoder_Token_NonCanonicalIntegers(t *testing.T) {
+	testCases := []struct {
+		Input         string
+		ExpectedError interface{}
+		LenientValue  interface{}
+	}{
+		{Input: "i03e", ExpectedError: &bencode.ErrLeadingZero{}, LenientValue: int64(3)},
+		{Input: "i-03e", ExpectedError: &bencode.ErrLeadingZero{}, LenientValue: int64(-3)},
+		{Input: "i00e", ExpectedError: &bencode.ErrLeadingZero{}, LenientValue: int64(0)},
+		{Input: "i-0e", ExpectedError: &bencode.ErrNegativeZero{}, LenientValue: int64(0)},
+		{Input: "i-00e", ExpectedError: &bencode.ErrNegativeZero{}, LenientValue: int64(0)},
+		{Input: "03:abc", ExpectedError: &bencode.ErrLeadingZero{}, LenientValue: []byte("abc")},
+		{Input: "ie", ExpectedError: &bencode.ErrEmptyInteger{}},
+		{Input: "i-e", ExpectedError: &bencode.ErrEmptyInteger{}},
+	}
+
+	lenientOptions := bencode.DefaultDecoderOptions
+	lenientOptions.AllowNonCanonicalIntegers = true
+
+	for i, tc := range testCases {
+		_, err := bencode.DecodeBytes([]byte(tc.Input))
+
+		var ok bool
+		switch tc.ExpectedError.(type) {
+		case *bencode.ErrLeadingZero:
+			var target *bencode.ErrLeadingZero
+			ok = errors.As(err, &target)
+		case *bencode.ErrNegativeZero:
+			var target *bencode.ErrNegativeZero
+			ok = errors.As(err, &target)
+		case *bencode.ErrEmptyInteger:
+			var target *bencode.ErrEmptyInteger
+			ok = errors.As(err, &target)
+		}
+
+		if !ok {
+			t.Errorf("testCases[%d]: DecodeBytes(%#v) returned %#v; want %T", i, tc.Input, err, tc.ExpectedError)
+		}
+
+		d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(tc.Input)), lenientOptions)
+
+		value, err := d.Decode()
+		if tc.LenientValue == nil {
+			if err == nil {
+				t.Errorf("testCases[%d]: lenient d.Decode(%#v) returned nil error", i, tc.Input)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("testCases[%d]: lenient d.Decode(%#v) returned error: %s", i, tc.Input, err)
+
+			continue
+		}
+
+		if got, want := value, tc.LenientValue; !reflect.DeepEqual(got, want) {
+			t.Errorf("testCases[%d]: lenient d.Decode(%#v) = %#v; want %#v", i, tc.Input, got, want)
+		}
+	}
+}
+
+func TestDecoder_Decode_BigIntegers(t *testing.T) {
+	input := "li1ei99999999999999999999ei-99999999999999999999ee"
+
+	if _, err := bencode.DecodeBytes([]byte(input)); !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("DecodeBytes() returned %#v; want strconv.ErrRange", err)
+	}
+
+	testCases := []struct {
+		Mode     bencode.BigIntegerMode
+		Expected []string
+	}{
+		{
+			Mode:     bencode.BigIntegersOnOverflow,
+			Expected: []string{"int64 1", "big 99999999999999999999", "big -99999999999999999999"},
+		},
+		{
+			Mode:     bencode.BigIntegersAlways,
+			Expected: []string{"big 1", "big 99999999999999999999", "big -99999999999999999999"},
+		},
+	}
+
+	for i, tc := range testCases {
+		options := bencode.DefaultDecoderOptions
+		options.BigIntegers = tc.Mode
+
+		d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+
+		value, err := d.Decode()
+		if err != nil {
+			t.Fatalf("testCases[%d]: d.Decode() returned error: %s", i, err)
+		}
+
+		var got []string
+		for _, item := range value.([]interface{}) {
+			switch item := item.(type) {
+			case int64:
+				got = append(got, "int64 "+strconv.FormatInt(item, 10))
+			case bencode.BigInteger:
+				got = append(got, "big "+item.String())
+			default:
+				got = append(got, fmt.Sprintf("%T", item))
+			}
+		}
+
+		if !reflect.DeepEqual(got, tc.Expected) {
+			t.Errorf("testCases[%d]: got = %#v; want %#v", i, got, tc.Expected)
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package bencode
 
 import (
-	"container/list"
+	"bytes"
+	"fmt"
+	"sort"
 	"sync"
 )
 
+// maxChunkSize is the maximum amount of items in each chunk of a
+// `Dictionary`. Inserting an item moves at most this many items, and a chunk
+// that grows larger is split in two.
+const maxChunkSize = 256
+
 type dictionaryItem struct {
 	Key   String
 	Value Value
@@ -22,8 +29,10 @@ func (di *dictionaryItem) Bencode() []byte {
 type Dictionary struct {
 	mu sync.RWMutex
 
-	l *list.List
-	m map[string]*list.Element
+	// chunks holds the items sorted by the raw bytes of their keys, split in
+	// consecutive chunks so that inserting an item doesn't move all the items
+	// after it. Chunks are never empty.
+	chunks [][]dictionaryItem
 }
 
 func (d *Dictionary) Bencode() []byte {
@@ -32,158 +41,330 @@ func (d *Dictionary) Bencode() []byte {
 
 	raw := []byte{'d'}
 
-	if d.l != nil {
-		for el := d.l.Front(); el != nil; el = el.Next() {
-			di := el.Value.(*dictionaryItem)
+	d.forEach(func(di *dictionaryItem) bool {
+		raw = append(raw, di.Bencode()...)
 
-			raw = append(raw, di.Bencode()...)
-		}
-	}
+		return true
+	})
 
 	raw = append(raw, byte('e'))
 
 	return raw
 }
 
-func (d *Dictionary) init() {
-	if d.l == nil {
-		d.l = list.New()
+// forEach calls `f` for each item in order, until it returns false. The
+// caller must hold `d.mu`.
+func (d *Dictionary) forEach(f func(di *dictionaryItem) bool) {
+	for _, chunk := range d.chunks {
+		for i := range chunk {
+			if !f(&chunk[i]) {
+				return
+			}
+		}
+	}
+}
+
+// search returns the position of `key`, as the index of its chunk and its
+// index within the chunk, or the position where it would be inserted, and
+// whether it was found.
+func (d *Dictionary) search(key String) (int, int, bool) {
+	n := len(d.chunks)
+	if n == 0 {
+		return 0, 0, false
 	}
 
-	if d.m == nil {
-		d.m = map[string]*list.Element{}
+	// Keys are often added in order, e.g. when decoding canonical input.
+	last := d.chunks[n-1]
+	if bytes.Compare(last[len(last)-1].Key, key) < 0 {
+		return n - 1, len(last), false
 	}
+
+	// Find the first chunk whose last key is not less than `key`.
+	c := sort.Search(n, func(c int) bool {
+		chunk := d.chunks[c]
+
+		return bytes.Compare(chunk[len(chunk)-1].Key, key) >= 0
+	})
+
+	chunk := d.chunks[c]
+
+	i := sort.Search(len(chunk), func(i int) bool {
+		return bytes.Compare(chunk[i].Key, key) >= 0
+	})
+
+	return c, i, bytes.Equal(chunk[i].Key, key)
 }
 
 func (d *Dictionary) Get(key String) (Value, bool) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	if d.m == nil {
+	c, i, ok := d.search(key)
+	if !ok {
 		return nil, false
 	}
 
-	var bk string
+	return d.chunks[c][i].Value, true
+}
+
+func (d *Dictionary) Set(key String, value Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, i, ok := d.search(key)
+	if ok {
+		d.chunks[c][i].Value = value
 
-	if len(key) > 0 {
-		bk = key.BencodeKey()
+		return
 	}
 
-	var (
-		ok bool
-		el *list.Element
-	)
+	di := dictionaryItem{
+		Key:   key,
+		Value: value,
+	}
 
-	el, ok = d.m[bk]
-	if !ok {
-		return nil, false
+	// Start a new chunk when appending to a full last chunk, so that keys
+	// added in order leave full chunks behind.
+	if len(d.chunks) == 0 || (c == len(d.chunks)-1 && i == maxChunkSize) {
+		chunk := make([]dictionaryItem, 1, maxChunkSize+1)
+		chunk[0] = di
+
+		d.chunks = append(d.chunks, chunk)
+
+		return
 	}
 
-	di := el.Value.(*dictionaryItem)
+	chunk := append(d.chunks[c], dictionaryItem{})
+	copy(chunk[i+1:], chunk[i:])
+	chunk[i] = di
 
-	return di.Value, true
+	d.chunks[c] = chunk
+
+	if len(chunk) > maxChunkSize {
+		d.split(c)
+	}
 }
 
-func (d *Dictionary) Set(key String, value Value) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// split splits the chunk at index `c` in two halves.
+func (d *Dictionary) split(c int) {
+	chunk := d.chunks[c]
+	half := len(chunk) / 2
 
-	var bk string
+	right := make([]dictionaryItem, len(chunk)-half, maxChunkSize+1)
+	copy(right, chunk[half:])
 
-	if len(key) > 0 {
-		bk = key.BencodeKey()
+	// Clear the moved items, so they can be garbage collected.
+	for i := half; i < len(chunk); i++ {
+		chunk[i] = dictionaryItem{}
 	}
 
-	d.init()
+	d.chunks = append(d.chunks, nil)
+	copy(d.chunks[c+2:], d.chunks[c+1:])
 
-	var (
-		ok bool
+	d.chunks[c] = chunk[:half]
+	d.chunks[c+1] = right
+}
 
-		el *list.Element
-	)
+func (d *Dictionary) Remove(key String) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	di := &dictionaryItem{
-		Key:   key,
-		Value: value,
+	c, i, ok := d.search(key)
+	if !ok {
+		return
 	}
 
-	el, ok = d.m[bk]
-	if ok {
-		el.Value = di
+	chunk := d.chunks[c]
+
+	copy(chunk[i:], chunk[i+1:])
+
+	// Clear the last item, so its key and value can be garbage collected.
+	chunk[len(chunk)-1] = dictionaryItem{}
+	chunk = chunk[:len(chunk)-1]
+
+	if len(chunk) > 0 {
+		d.chunks[c] = chunk
 
 		return
-	} else {
-		el = nil
 	}
 
-	var markBeforeInsert *list.Element
+	copy(d.chunks[c:], d.chunks[c+1:])
+	d.chunks[len(d.chunks)-1] = nil
+	d.chunks = d.chunks[:len(d.chunks)-1]
+}
 
-	var (
-		currentElement *list.Element = d.l.Front()
-		nextElement    *list.Element
-	)
+// Len returns the amount of keys in the dictionary.
+func (d *Dictionary) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	for currentElement != nil {
-		nextElement = currentElement.Next()
+	n := 0
+	for _, chunk := range d.chunks {
+		n += len(chunk)
+	}
 
-		cedi := currentElement.Value.(*dictionaryItem)
-		cebk := cedi.Key.BencodeKey()
+	return n
+}
 
-		if nextElement == nil {
-			if bk >= cebk {
-				markBeforeInsert = currentElement
+// Keys returns the keys of the dictionary, sorted by their raw bytes.
+func (d *Dictionary) Keys() []String {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-				break
-			}
-		} else {
-			nedi := nextElement.Value.(*dictionaryItem)
-			nebk := nedi.Key.BencodeKey()
+	var keys []String
 
-			if bk > cebk && bk < nebk {
-				markBeforeInsert = currentElement
+	d.forEach(func(di *dictionaryItem) bool {
+		keys = append(keys, di.Key)
 
-				break
-			}
-		}
+		return true
+	})
 
-		currentElement = nextElement
-		nextElement = nil
-	}
+	return keys
+}
 
-	if markBeforeInsert == nil {
-		el = d.l.PushFront(di)
-	} else {
-		el = d.l.InsertAfter(di, markBeforeInsert)
+// Range calls `f` for each key and value of the dictionary, sorted by the raw
+// bytes of the keys, until `f` returns false.
+//
+// `f` sees the items that were in the dictionary when `Range` was called, so
+// it can safely modify the dictionary.
+func (d *Dictionary) Range(f func(key String, value Value) bool) {
+	for _, di := range d.snapshot() {
+		if !f(di.Key, di.Value) {
+			return
+		}
 	}
+}
+
+// snapshot returns a copy of the items of the dictionary.
+func (d *Dictionary) snapshot() []dictionaryItem {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var items []dictionaryItem
 
-	d.m[bk] = el
+	d.forEach(func(di *dictionaryItem) bool {
+		items = append(items, *di)
+
+		return true
+	})
+
+	return items
 }
 
-func (d *Dictionary) Remove(key String) {
+// SetAll sets every key and value in `items`.
+//
+// It sorts the new keys once and merges them with the existing ones, which is
+// faster than calling `Set` for each key when adding many keys.
+func (d *Dictionary) SetAll(items map[string]Value) {
+	newItems := make([]dictionaryItem, 0, len(items))
+	for key, value := range items {
+		newItems = append(newItems, dictionaryItem{
+			Key:   String(key),
+			Value: value,
+		})
+	}
+
+	sort.Slice(newItems, func(i, j int) bool {
+		return bytes.Compare(newItems[i].Key, newItems[j].Key) < 0
+	})
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	var bk string
-
-	if len(key) > 0 {
-		bk = key.BencodeKey()
+	var existing []dictionaryItem
+	d.forEach(func(di *dictionaryItem) bool {
+		existing = append(existing, *di)
+
+		return true
+	})
+
+	merged := make([]dictionaryItem, 0, len(existing)+len(newItems))
+	for len(existing) > 0 && len(newItems) > 0 {
+		switch c := bytes.Compare(existing[0].Key, newItems[0].Key); {
+		case c < 0:
+			merged = append(merged, existing[0])
+			existing = existing[1:]
+		case c > 0:
+			merged = append(merged, newItems[0])
+			newItems = newItems[1:]
+		default:
+			merged = append(merged, newItems[0])
+			existing = existing[1:]
+			newItems = newItems[1:]
+		}
 	}
+	merged = append(merged, existing...)
+	merged = append(merged, newItems...)
 
-	d.init()
+	d.chunks = chunksOf(merged)
+}
 
-	var (
-		ok bool
-		el *list.Element
-	)
+// chunksOf splits `items`, which must be sorted by key without duplicates,
+// into full chunks.
+func chunksOf(items []dictionaryItem) [][]dictionaryItem {
+	var chunks [][]dictionaryItem
 
-	el, ok = d.m[bk]
-	if ok {
-		d.l.Remove(el)
+	for len(items) > 0 {
+		n := len(items)
+		if n > maxChunkSize {
+			n = maxChunkSize
+		}
 
-		delete(d.m, bk)
+		chunk := make([]dictionaryItem, n, maxChunkSize+1)
+		copy(chunk, items[:n])
+
+		chunks = append(chunks, chunk)
+		items = items[n:]
 	}
+
+	return chunks
 }
 
 func NewDictionary() *Dictionary {
 	return &Dictionary{}
 }
+
+// DictionaryFromMap returns a dictionary with the keys and values of `m`.
+func DictionaryFromMap(m map[string]Value) *Dictionary {
+	d := NewDictionary()
+	d.SetAll(m)
+
+	return d
+}
+
+// DictionaryOf returns a dictionary with the given keys and values, which
+// alternate: `DictionaryOf("a", Integer(1), "b", String("x"))`. Keys can be
+// `string` or `String`. If a key appears more than once, the last value is
+// kept.
+//
+// It panics if the amount of arguments is odd, or if any of them has the wrong
+// type, so it's meant for literal values.
+func DictionaryOf(keysAndValues ...interface{}) *Dictionary {
+	if len(keysAndValues)%2 != 0 {
+		panic(fmt.Sprintf("bencode: DictionaryOf called with an odd number of arguments: %d", len(keysAndValues)))
+	}
+
+	items := make(map[string]Value, len(keysAndValues)/2)
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var key string
+
+		switch k := keysAndValues[i].(type) {
+		case string:
+			key = k
+		case String:
+			key = string(k)
+		default:
+			panic(fmt.Sprintf("bencode: DictionaryOf argument %d is a %T, not a key", i, keysAndValues[i]))
+		}
+
+		value, ok := keysAndValues[i+1].(Value)
+		if !ok {
+			panic(fmt.Sprintf("bencode: DictionaryOf argument %d is a %T, not a Value", i+1, keysAndValues[i+1]))
+		}
+
+		items[key] = value
+	}
+
+	return DictionaryFromMap(items)
+}
@@ -0,0 +1,13 @@
+//go:build go1.23
+
+package bencode
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the keys and values of the dictionary, sorted
+// by the raw bytes of the keys. It behaves like `Range`.
+func (d *Dictionary) All() iter.Seq2[String, Value] {
+	return d.Range
+}
@@ -0,0 +1,33 @@
+//go:build go1.23
+
+package bencode_test
+
+import (
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+func TestDictionary_All(t *testing.T) {
+	d := makeDictionary(map[string]bencode.Value{
+		"b": bencode.Integer(2),
+		"a": bencode.Integer(1),
+		"c": bencode.Integer(3),
+	})
+
+	var got []string
+	for key, value := range d.All() {
+		got = append(got, string(key)+"="+string(value.Bencode()))
+
+		if string(key) == "b" {
+			break
+		}
+	}
+
+	if got, want := len(got), 2; got != want {
+		t.Fatalf("len(got) = %#v; want %#v", got, want)
+	}
+	if got, want := got[0]+","+got[1], "a=i1e,b=i2e"; got != want {
+		t.Errorf("got = %#v; want %#v", got, want)
+	}
+}
@@ -1,6 +1,8 @@
 package bencode_test
 
 import (
+	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/c032/go-bencode"
@@ -56,3 +58,301 @@ func TestDictionary_Bencode(t *testing.T) {
 		}
 	}
 }
+
+func TestDictionary_SetRemove(t *testing.T) {
+	d := bencode.NewDictionary()
+
+	// Insert keys out of order, including the empty key, and a key that is a
+	// prefix of another.
+	for _, key := range []string{"b", "", "ab", "c", "a", "b"} {
+		d.Set(bencode.String(key), bencode.String(key))
+	}
+
+	d.Set(bencode.String("c"), bencode.Integer(3))
+	d.Remove(bencode.String("ab"))
+	d.Remove(bencode.String("missing"))
+
+	if got, want := string(d.Bencode()), "d0:0:1:a1:a1:b1:b1:ci3ee"; got != want {
+		t.Errorf("d.Bencode() = %#v; want %#v", got, want)
+	}
+
+	if _, ok := d.Get(bencode.String("ab")); ok {
+		t.Errorf("d.Get(\"ab\") found a removed key")
+	}
+
+	if got, ok := d.Get(bencode.String("c")); !ok || got != bencode.Integer(3) {
+		t.Errorf("d.Get(\"c\") = %#v, %#v; want %#v, true", got, ok, bencode.Integer(3))
+	}
+}
+
+func TestDictionary_SetRemove_Large(t *testing.T) {
+	for _, isShuffled := range []bool{false, true} {
+		keys := dictionaryBenchmarkKeys(2000, isShuffled)
+
+		d := bencode.NewDictionary()
+		for _, key := range keys {
+			d.Set(key, bencode.Integer(1))
+		}
+
+		// Remove every odd key.
+		for _, key := range keys {
+			var n int
+			fmt.Sscanf(string(key), "files/%d", &n)
+
+			if n%2 == 1 {
+				d.Remove(key)
+			}
+		}
+
+		want := []byte{'d'}
+		for i := 0; i < 2000; i += 2 {
+			want = append(want, bencode.String(fmt.Sprintf("files/%08d", i)).Bencode()...)
+			want = append(want, "i1e"...)
+		}
+		want = append(want, 'e')
+
+		if got := d.Bencode(); !sameByteSlice(got, want) {
+			t.Errorf("isShuffled=%#v: d.Bencode() = %#v; want %#v", isShuffled, string(got), string(want))
+		}
+	}
+}
+
+// dictionaryBenchmarkKeys returns `n` distinct keys, shuffled if `isShuffled`
+// is true, or sorted otherwise.
+func dictionaryBenchmarkKeys(n int, isShuffled bool) []bencode.String {
+	keys := make([]bencode.String, n)
+	for i := range keys {
+		keys[i] = bencode.String(fmt.Sprintf("files/%08d", i))
+	}
+
+	if isShuffled {
+		r := rand.New(rand.NewSource(1))
+		r.Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+	}
+
+	return keys
+}
+
+func BenchmarkDictionary_Set(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		for _, isShuffled := range []bool{false, true} {
+			name := fmt.Sprintf("keys=%d/sorted", n)
+			if isShuffled {
+				name = fmt.Sprintf("keys=%d/shuffled", n)
+			}
+
+			keys := dictionaryBenchmarkKeys(n, isShuffled)
+
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+
+				for i := 0; i < b.N; i++ {
+					d := bencode.NewDictionary()
+					for _, key := range keys {
+						d.Set(key, bencode.Integer(1))
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDictionary_Get(b *testing.B) {
+	keys := dictionaryBenchmarkKeys(100000, true)
+
+	d := bencode.NewDictionary()
+	for _, key := range keys {
+		d.Set(key, bencode.Integer(1))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, ok := d.Get(keys[i%len(keys)]); !ok {
+			b.Fatal("missing key")
+		}
+	}
+}
+
+func TestDictionary_Keys(t *testing.T) {
+	d := makeDictionary(map[string]bencode.Value{
+		"foo": bencode.Integer(42),
+		"bar": bencode.String("spam"),
+		"":    bencode.List{},
+	})
+
+	if got, want := d.Len(), 3; got != want {
+		t.Errorf("d.Len() = %#v; want %#v", got, want)
+	}
+
+	keys := d.Keys()
+	if got, want := fmt.Sprintf("%q", keys), `["" "bar" "foo"]`; got != want {
+		t.Errorf("d.Keys() = %s; want %s", got, want)
+	}
+
+	if got, want := bencode.NewDictionary().Len(), 0; got != want {
+		t.Errorf("NewDictionary().Len() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDictionary_Range(t *testing.T) {
+	d := makeDictionary(map[string]bencode.Value{
+		"a": bencode.Integer(1),
+		"b": bencode.Integer(2),
+		"c": bencode.Integer(3),
+	})
+
+	var got []string
+	d.Range(func(key bencode.String, value bencode.Value) bool {
+		got = append(got, string(key))
+
+		// Modifying the dictionary while ranging over it must not deadlock.
+		d.Remove(key)
+
+		return string(key) != "b"
+	})
+
+	if got, want := fmt.Sprintf("%q", got), `["a" "b"]`; got != want {
+		t.Errorf("keys = %s; want %s", got, want)
+	}
+
+	if got, want := string(d.Bencode()), "d1:ci3ee"; got != want {
+		t.Errorf("d.Bencode() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDictionaryFromMap(t *testing.T) {
+	d := bencode.DictionaryFromMap(map[string]bencode.Value{
+		"foo": bencode.Integer(42),
+		"bar": bencode.String("spam"),
+	})
+
+	if got, want := string(d.Bencode()), "d3:bar4:spam3:fooi42ee"; got != want {
+		t.Errorf("d.Bencode() = %#v; want %#v", got, want)
+	}
+
+	if got, want := string(bencode.DictionaryFromMap(nil).Bencode()), "de"; got != want {
+		t.Errorf("DictionaryFromMap(nil).Bencode() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDictionaryOf(t *testing.T) {
+	d := bencode.DictionaryOf(
+		"interval", bencode.Integer(1800),
+		bencode.String("complete"), bencode.Integer(3),
+		"interval", bencode.Integer(900),
+		"peers", bencode.List{},
+	)
+
+	if got, want := string(d.Bencode()), "d8:completei3e8:intervali900e5:peerslee"; got != want {
+		t.Errorf("d.Bencode() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDictionaryOf_Panic(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Input []interface{}
+	}{
+		{
+			Name:  "odd",
+			Input: []interface{}{"foo"},
+		},
+		{
+			Name:  "key",
+			Input: []interface{}{42, bencode.Integer(42)},
+		},
+		{
+			Name:  "value",
+			Input: []interface{}{"foo", 42},
+		},
+	}
+
+	for _, tc := range testCases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: DictionaryOf(%#v) did not panic", tc.Name, tc.Input)
+				}
+			}()
+
+			bencode.DictionaryOf(tc.Input...)
+		}()
+	}
+}
+
+func TestDictionary_SetAll(t *testing.T) {
+	d := makeDictionary(map[string]bencode.Value{
+		"a": bencode.Integer(1),
+		"c": bencode.Integer(3),
+		"e": bencode.Integer(5),
+	})
+
+	d.SetAll(map[string]bencode.Value{
+		"b": bencode.Integer(2),
+		"c": bencode.Integer(30),
+		"f": bencode.Integer(6),
+	})
+
+	if got, want := string(d.Bencode()), "d1:ai1e1:bi2e1:ci30e1:ei5e1:fi6ee"; got != want {
+		t.Errorf("d.Bencode() = %#v; want %#v", got, want)
+	}
+
+	// The result must still support regular updates.
+	d.Set(bencode.String("d"), bencode.Integer(4))
+	d.Remove(bencode.String("a"))
+
+	if got, want := string(d.Bencode()), "d1:bi2e1:ci30e1:di4e1:ei5e1:fi6ee"; got != want {
+		t.Errorf("d.Bencode() = %#v; want %#v", got, want)
+	}
+}
+
+func TestDictionary_SetAll_Large(t *testing.T) {
+	keys := dictionaryBenchmarkKeys(2000, true)
+
+	// Half of the keys are set one by one, and all of them in bulk.
+	d := bencode.NewDictionary()
+	for _, key := range keys[:1000] {
+		d.Set(key, bencode.Integer(0))
+	}
+
+	items := make(map[string]bencode.Value, len(keys))
+	for _, key := range keys {
+		items[string(key)] = bencode.Integer(1)
+	}
+	d.SetAll(items)
+
+	want := []byte{'d'}
+	for i := 0; i < 2000; i++ {
+		want = append(want, bencode.String(fmt.Sprintf("files/%08d", i)).Bencode()...)
+		want = append(want, "i1e"...)
+	}
+	want = append(want, 'e')
+
+	if got := d.Bencode(); !sameByteSlice(got, want) {
+		t.Errorf("d.Bencode() = %#v; want %#v", string(got), string(want))
+	}
+
+	if got, want := d.Len(), 2000; got != want {
+		t.Errorf("d.Len() = %#v; want %#v", got, want)
+	}
+}
+
+func BenchmarkDictionaryFromMap(b *testing.B) {
+	keys := dictionaryBenchmarkKeys(100000, true)
+
+	items := make(map[string]bencode.Value, len(keys))
+	for _, key := range keys {
+		items[string(key)] = bencode.Integer(1)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		bencode.DictionaryFromMap(items)
+	}
+}
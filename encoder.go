@@ -0,0 +1,306 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// ErrInvalidWrite is returned by the `Encoder` methods when the write would
+// produce invalid bencode, such as a non-string dictionary key, a dictionary
+// key that is not greater than the previous one, or an `End` without a
+// matching container.
+type ErrInvalidWrite struct {
+	Offset int64
+	Reason string
+}
+
+func (e *ErrInvalidWrite) Error() string {
+	return fmt.Sprintf("invalid write at offset %d: %s", e.Offset, e.Reason)
+}
+
+var _ error = (*ErrInvalidWrite)(nil)
+
+type encoderFrame struct {
+	isDictionary bool
+
+	// expectKey is true when the next value in a dictionary must be a key.
+	expectKey bool
+
+	hasKey  bool
+	lastKey []byte
+}
+
+// Encoder writes bencode values to an output stream.
+//
+// Values are written to the underlying writer as soon as they are produced,
+// without buffering them in memory, so the writer should be wrapped in a
+// `bufio.Writer` if small writes are expensive.
+//
+// The low-level methods (`StartDict`, `StartList`, `String`, `StringReader`,
+// `Integer`, `BigInteger` and `End`) can be freely mixed with `Encode`. The
+// encoder keeps track of the open containers, and refuses to write anything
+// that would produce invalid bencode. Once a write fails, every following
+// call returns the same error.
+type Encoder struct {
+	w io.Writer
+
+	offset int64
+	stack  []*encoderFrame
+	err    error
+
+	// ptrLevel is the amount of pointers, maps and slices that `Encode` is
+	// inside of, and ptrSeen holds them once cycles are being detected.
+	ptrLevel uint
+	ptrSeen  map[cycleKey]struct{}
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w: w,
+	}
+}
+
+// Offset returns the amount of bytes written so far.
+func (e *Encoder) Offset() int64 {
+	return e.offset
+}
+
+func (e *Encoder) write(b []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	n, err := e.w.Write(b)
+	e.offset += int64(n)
+	if err != nil {
+		e.err = fmt.Errorf("write error: %w", err)
+
+		return e.err
+	}
+
+	return nil
+}
+
+func (e *Encoder) invalid(reason string) error {
+	e.err = &ErrInvalidWrite{
+		Offset: e.offset,
+		Reason: reason,
+	}
+
+	return e.err
+}
+
+// prepare validates that a value can be written at the current position, and
+// updates the state of the innermost container accordingly.
+//
+// `key` is only used when `isString` is true, and holds the contents of the
+// string.
+func (e *Encoder) prepare(isString bool, key []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if len(e.stack) == 0 {
+		return nil
+	}
+
+	f := e.stack[len(e.stack)-1]
+	if !f.isDictionary {
+		return nil
+	}
+
+	if !f.expectKey {
+		f.expectKey = true
+
+		return nil
+	}
+
+	if !isString {
+		return e.invalid("dictionary keys must be strings")
+	}
+
+	if f.hasKey && bytes.Compare(key, f.lastKey) <= 0 {
+		return e.invalid(fmt.Sprintf("dictionary key %q is not greater than previous key %q", key, f.lastKey))
+	}
+
+	f.hasKey = true
+	f.lastKey = append(f.lastKey[:0], key...)
+	f.expectKey = false
+
+	return nil
+}
+
+// isKeyExpected reports whether the next value must be a dictionary key.
+func (e *Encoder) isKeyExpected() bool {
+	if len(e.stack) == 0 {
+		return false
+	}
+
+	f := e.stack[len(e.stack)-1]
+
+	return f.isDictionary && f.expectKey
+}
+
+// StartDict opens a dictionary. It must be followed by alternating keys and
+// values, with keys written by `String` in strictly increasing order, and
+// closed with `End`.
+func (e *Encoder) StartDict() error {
+	if err := e.prepare(false, nil); err != nil {
+		return err
+	}
+
+	if err := e.write([]byte{'d'}); err != nil {
+		return err
+	}
+
+	e.stack = append(e.stack, &encoderFrame{
+		isDictionary: true,
+		expectKey:    true,
+	})
+
+	return nil
+}
+
+// StartList opens a list, which must be closed with `End`.
+func (e *Encoder) StartList() error {
+	if err := e.prepare(false, nil); err != nil {
+		return err
+	}
+
+	if err := e.write([]byte{'l'}); err != nil {
+		return err
+	}
+
+	e.stack = append(e.stack, &encoderFrame{})
+
+	return nil
+}
+
+// End closes the innermost open dictionary or list.
+func (e *Encoder) End() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if len(e.stack) == 0 {
+		return e.invalid("end without an open dictionary or list")
+	}
+
+	f := e.stack[len(e.stack)-1]
+	if f.isDictionary && !f.expectKey {
+		return e.invalid(fmt.Sprintf("missing value for dictionary key %q", f.lastKey))
+	}
+
+	if err := e.write([]byte{'e'}); err != nil {
+		return err
+	}
+
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return nil
+}
+
+// String writes a byte string.
+func (e *Encoder) String(s []byte) error {
+	if err := e.prepare(true, s); err != nil {
+		return err
+	}
+
+	prefix := strconv.AppendInt(nil, int64(len(s)), 10)
+	prefix = append(prefix, ':')
+
+	if err := e.write(prefix); err != nil {
+		return err
+	}
+
+	return e.write(s)
+}
+
+// StringReader writes a byte string of `length` bytes, copying its contents
+// from `r` instead of holding them in memory.
+//
+// It can't be used for dictionary keys, because they have to be compared
+// against the previous key.
+func (e *Encoder) StringReader(length int64, r io.Reader) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if e.isKeyExpected() {
+		return e.invalid("dictionary keys can't be written from a reader")
+	}
+
+	if length < 0 {
+		return e.invalid(fmt.Sprintf("negative string length %d", length))
+	}
+
+	if err := e.prepare(true, nil); err != nil {
+		return err
+	}
+
+	prefix := strconv.AppendInt(nil, length, 10)
+	prefix = append(prefix, ':')
+
+	if err := e.write(prefix); err != nil {
+		return err
+	}
+
+	n, err := io.CopyN(e.w, r, length)
+	e.offset += n
+	if err != nil {
+		e.err = fmt.Errorf("could not copy string: %w", err)
+
+		return e.err
+	}
+
+	return nil
+}
+
+// Integer writes an integer.
+func (e *Encoder) Integer(n int64) error {
+	return e.writeInteger(strconv.FormatInt(n, 10))
+}
+
+// BigInteger writes an integer of arbitrary size. A nil `n` is written as zero.
+func (e *Encoder) BigInteger(n *big.Int) error {
+	if n == nil {
+		return e.writeInteger("0")
+	}
+
+	return e.writeInteger(n.String())
+}
+
+// writeInteger writes an integer given its decimal representation.
+func (e *Encoder) writeInteger(digits string) error {
+	if err := e.prepare(false, nil); err != nil {
+		return err
+	}
+
+	raw := make([]byte, 0, len(digits)+2)
+	raw = append(raw, 'i')
+	raw = append(raw, digits...)
+	raw = append(raw, 'e')
+
+	return e.write(raw)
+}
+
+// writeRaw writes a complete, already encoded, value that is not a dictionary
+// key.
+func (e *Encoder) writeRaw(raw []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if e.isKeyExpected() {
+		return e.invalid("dictionary keys must be written with String")
+	}
+
+	if err := e.prepare(false, nil); err != nil {
+		return err
+	}
+
+	return e.write(raw)
+}
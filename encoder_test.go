@@ -0,0 +1,183 @@
+package bencode_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+func TestEncoder_LowLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	e := bencode.NewEncoder(buf)
+
+	steps := []func() error{
+		e.StartDict,
+		func() error { return e.String([]byte("a")) },
+		e.StartList,
+		func() error { return e.Integer(-1) },
+		func() error { return e.StringReader(4, strings.NewReader("spam")) },
+		e.End,
+		func() error { return e.String([]byte("b")) },
+		func() error { return e.Encode(map[string]int{"x": 1}) },
+		e.End,
+		func() error { return e.Integer(7) },
+	}
+
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("steps[%d]() returned error: %s", i, err)
+		}
+	}
+
+	if got, expected := buf.String(), "d1:ali-1e4:spame1:bd1:xi1eeei7e"; got != expected {
+		t.Errorf("buf.String() = %#v; expected %#v", got, expected)
+	}
+
+	if got, expected := e.Offset(), int64(buf.Len()); got != expected {
+		t.Errorf("e.Offset() = %#v; expected %#v", got, expected)
+	}
+}
+
+func TestEncoder_InvalidWrite(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Steps func(e *bencode.Encoder) error
+	}{
+		{
+			Name: "end without container",
+			Steps: func(e *bencode.Encoder) error {
+				return e.End()
+			},
+		},
+		{
+			Name: "integer key",
+			Steps: func(e *bencode.Encoder) error {
+				if err := e.StartDict(); err != nil {
+					return err
+				}
+
+				return e.Integer(1)
+			},
+		},
+		{
+			Name: "unsorted keys",
+			Steps: func(e *bencode.Encoder) error {
+				if err := e.StartDict(); err != nil {
+					return err
+				}
+				if err := e.String([]byte("b")); err != nil {
+					return err
+				}
+				if err := e.Integer(1); err != nil {
+					return err
+				}
+
+				return e.String([]byte("a"))
+			},
+		},
+		{
+			Name: "duplicate keys",
+			Steps: func(e *bencode.Encoder) error {
+				if err := e.StartDict(); err != nil {
+					return err
+				}
+				if err := e.String([]byte("a")); err != nil {
+					return err
+				}
+				if err := e.Integer(1); err != nil {
+					return err
+				}
+
+				return e.String([]byte("a"))
+			},
+		},
+		{
+			Name: "missing value",
+			Steps: func(e *bencode.Encoder) error {
+				if err := e.StartDict(); err != nil {
+					return err
+				}
+				if err := e.String([]byte("a")); err != nil {
+					return err
+				}
+
+				return e.End()
+			},
+		},
+		{
+			Name: "key from reader",
+			Steps: func(e *bencode.Encoder) error {
+				if err := e.StartDict(); err != nil {
+					return err
+				}
+
+				return e.StringReader(1, strings.NewReader("a"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		e := bencode.NewEncoder(&bytes.Buffer{})
+
+		err := tc.Steps(e)
+
+		var invalidErr *bencode.ErrInvalidWrite
+		if !errors.As(err, &invalidErr) {
+			t.Errorf("%s: err = %#v; expected *bencode.ErrInvalidWrite", tc.Name, err)
+
+			continue
+		}
+
+		if got := e.Integer(0); got != err {
+			t.Errorf("%s: e.Integer(0) after error = %#v; expected %#v", tc.Name, got, err)
+		}
+	}
+}
+
+func TestEncoder_Encode_Dictionary(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	d := makeDictionary(map[string]bencode.Value{
+		"foo": bencode.Integer(42),
+		"bar": bencode.List{bencode.String("spam")},
+	})
+
+	if err := bencode.NewEncoder(buf).Encode(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := buf.String(), string(d.Bencode()); got != expected {
+		t.Errorf("buf.String() = %#v; expected %#v", got, expected)
+	}
+}
+
+func TestEncoder_Encode_ErrorIsSticky(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e := bencode.NewEncoder(buf)
+
+	err := e.Encode(map[string]interface{}{
+		"a": 1,
+		"b": make(chan int),
+	})
+
+	var typeErr *bencode.ErrUnsupportedType
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("err = %#v; expected *bencode.ErrUnsupportedType", err)
+	}
+
+	if got := e.Encode(5); got != err {
+		t.Errorf("e.Encode(5) after error = %#v; expected %#v", got, err)
+	}
+
+	if got := e.End(); got != err {
+		t.Errorf("e.End() after error = %#v; expected %#v", got, err)
+	}
+
+	if got, expected := buf.String(), "d1:ai1e1:b"; got != expected {
+		t.Errorf("buf.String() = %#v; expected %#v", got, expected)
+	}
+}
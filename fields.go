@@ -0,0 +1,160 @@
+package bencode
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// field describes a struct field that is mapped to a dictionary key.
+type field struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	tagged    bool
+	omitEmpty bool
+}
+
+// structFields holds the fields of a struct type, sorted by the raw bytes of
+// their names, which is the order in which they must be encoded.
+type structFields struct {
+	list   []field
+	byName map[string]int
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// parseTag splits a `bencode` struct tag into its name and options.
+func parseTag(tag string) (name string, omitEmpty bool) {
+	parts := strings.Split(tag, ",")
+
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty
+}
+
+// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
+func cachedTypeFields(t reflect.Type) *structFields {
+	if sf, ok := fieldCache.Load(t); ok {
+		return sf.(*structFields)
+	}
+
+	sf, _ := fieldCache.LoadOrStore(t, typeFields(t))
+
+	return sf.(*structFields)
+}
+
+// typeFields returns the fields that should be recognized for the given struct
+// type.
+//
+// Fields of embedded structs without a name in their tag are promoted, in the
+// same way as Go promotes them: a field at a shallower depth hides fields with
+// the same name at deeper depths, and conflicting fields at the same depth are
+// ignored unless exactly one of them is tagged.
+func typeFields(t reflect.Type) *structFields {
+	var all []field
+
+	collectFields(t, nil, map[reflect.Type]bool{}, &all)
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].name != all[j].name {
+			return all[i].name < all[j].name
+		}
+		if len(all[i].index) != len(all[j].index) {
+			return len(all[i].index) < len(all[j].index)
+		}
+
+		return all[i].tagged && !all[j].tagged
+	})
+
+	sf := &structFields{
+		byName: map[string]int{},
+	}
+
+	for i := 0; i < len(all); {
+		j := i + 1
+		for j < len(all) && all[j].name == all[i].name {
+			j++
+		}
+
+		candidates := all[i:j]
+		i = j
+
+		dominant := candidates[0]
+		if len(candidates) > 1 {
+			next := candidates[1]
+			if len(next.index) == len(dominant.index) && next.tagged == dominant.tagged {
+				continue
+			}
+		}
+
+		sf.byName[dominant.name] = len(sf.list)
+		sf.list = append(sf.list, dominant)
+	}
+
+	return sf
+}
+
+func collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool, dst *[]field) {
+	if visited[t] {
+		return
+	}
+
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("bencode")
+		if tag == "-" {
+			continue
+		}
+
+		name, omitEmpty := parseTag(tag)
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			// Unexported embedded structs are still walked, because their
+			// exported fields are promoted. Embedded pointers to unexported
+			// structs are not, because they cannot be allocated when
+			// decoding.
+			if name == "" && ft.Kind() == reflect.Struct && (sf.IsExported() || sf.Type.Kind() != reflect.Ptr) {
+				collectFields(ft, fieldIndex, visited, dst)
+
+				continue
+			}
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+
+		*dst = append(*dst, field{
+			name:      name,
+			index:     fieldIndex,
+			typ:       sf.Type,
+			tagged:    tagged,
+			omitEmpty: omitEmpty,
+		})
+	}
+}
@@ -2,6 +2,7 @@ package bencode
 
 import (
 	"fmt"
+	"math/big"
 )
 
 type Integer int64
@@ -11,3 +12,20 @@ func (i Integer) Bencode() []byte {
 
 	return []byte(iStr)
 }
+
+// BigInteger is an integer of arbitrary size. A nil `Int` is zero.
+type BigInteger struct {
+	*big.Int
+}
+
+func (i BigInteger) Bencode() []byte {
+	if i.Int == nil {
+		return []byte("i0e")
+	}
+
+	raw := []byte{'i'}
+	raw = i.Int.Append(raw, 10)
+	raw = append(raw, 'e')
+
+	return raw
+}
@@ -1,6 +1,7 @@
 package bencode_test
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/c032/go-bencode"
@@ -31,3 +32,31 @@ func TestInteger_Bencode(t *testing.T) {
 		}
 	}
 }
+
+func TestBigInteger_Bencode(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-99999999999999999999", 10)
+
+	testCases := []struct {
+		Value           bencode.BigInteger
+		ExpectedBencode []byte
+	}{
+		{
+			Value:           bencode.BigInteger{},
+			ExpectedBencode: []byte("i0e"),
+		},
+		{
+			Value:           bencode.BigInteger{big.NewInt(1)},
+			ExpectedBencode: []byte("i1e"),
+		},
+		{
+			Value:           bencode.BigInteger{huge},
+			ExpectedBencode: []byte("i-99999999999999999999e"),
+		},
+	}
+
+	for i, tc := range testCases {
+		if got, expected := string(tc.Value.Bencode()), string(tc.ExpectedBencode); got != expected {
+			t.Errorf("testCases[%d].Value.Bencode() = %#v; expected %#v", i, got, expected)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package bencode
+
+// Kind is the kind of a bencode value, or of the token that starts it.
+type Kind int
+
+const (
+	KindInvalid Kind = iota
+	KindInteger
+	KindString
+	KindList
+	KindDictionary
+
+	// KindEnd is the kind of the token that closes a list or dictionary.
+	KindEnd
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInteger:
+		return "integer"
+	case KindString:
+		return "string"
+	case KindList:
+		return "list"
+	case KindDictionary:
+		return "dictionary"
+	case KindEnd:
+		return "end"
+	default:
+		return "invalid"
+	}
+}
+
+// kindOfByte returns the kind of the token that starts with `c`.
+func kindOfByte(c byte) Kind {
+	switch {
+	case c == 'i':
+		return KindInteger
+	case c >= '0' && c <= '9':
+		return KindString
+	case c == 'l':
+		return KindList
+	case c == 'd':
+		return KindDictionary
+	case c == 'e':
+		return KindEnd
+	default:
+		return KindInvalid
+	}
+}
+
+// kindOfToken returns the kind of `token`.
+func kindOfToken(token Token) Kind {
+	switch token.(type) {
+	case *TokenInteger:
+		return KindInteger
+	case *TokenString, *TokenSkippedString, *TokenStringReader:
+		return KindString
+	case *TokenListStart:
+		return KindList
+	case *TokenDictionaryStart:
+		return KindDictionary
+	case *TokenEnd:
+		return KindEnd
+	default:
+		return KindInvalid
+	}
+}
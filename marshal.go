@@ -0,0 +1,487 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ErrUnsupportedType is returned when trying to encode a Go value whose type
+// has no bencode representation, such as floats, channels or functions.
+type ErrUnsupportedType struct {
+	Type reflect.Type
+}
+
+func (e *ErrUnsupportedType) Error() string {
+	return fmt.Sprintf("unsupported type: %s", e.Type)
+}
+
+// ErrUnsupportedValue is returned when trying to encode a Go value that has no
+// bencode representation, such as a nil pointer outside of a dictionary.
+type ErrUnsupportedValue struct {
+	Type   reflect.Type
+	Reason string
+}
+
+func (e *ErrUnsupportedValue) Error() string {
+	if e.Type == nil {
+		return fmt.Sprintf("unsupported nil value: %s", e.Reason)
+	}
+
+	return fmt.Sprintf("unsupported value of type %s: %s", e.Type, e.Reason)
+}
+
+// ErrMarshaler is returned when the `MarshalBencode` method of a type returns
+// an error, or returns something that is not exactly one valid bencode value.
+type ErrMarshaler struct {
+	Type reflect.Type
+	Err  error
+}
+
+func (e *ErrMarshaler) Error() string {
+	return fmt.Sprintf("error calling MarshalBencode for type %s: %s", e.Type, e.Err)
+}
+
+func (e *ErrMarshaler) Unwrap() error {
+	return e.Err
+}
+
+var (
+	_ error = (*ErrUnsupportedType)(nil)
+	_ error = (*ErrUnsupportedValue)(nil)
+	_ error = (*ErrMarshaler)(nil)
+)
+
+// Marshaler is the interface implemented by types that can encode themselves
+// into bencode.
+//
+// `MarshalBencode` must return exactly one complete bencode value, in
+// canonical form: dictionary keys must be sorted by their raw bytes, and
+// appear only once. `RawMessage` is the only exception, and only needs to be
+// a complete value.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+var (
+	marshalerType  = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	rawMessageType = reflect.TypeOf(RawMessage(nil))
+)
+
+// Marshal returns the bencode encoding of `v`.
+//
+// Integers, `big.Int` and booleans are encoded as integers (`true` is `i1e`,
+// and `false` is `i0e`). Strings, byte slices and byte arrays are encoded as
+// strings. Other slices and arrays are encoded as lists. Structs, and maps
+// with string keys, are encoded as dictionaries, with their keys sorted by
+// their raw bytes. Pointers and interfaces are encoded as the value they point
+// to. Values that implement `Marshaler` are encoded with their
+// `MarshalBencode` method, and values that implement `Value` are encoded with
+// their `Bencode` method. A `Dictionary` that is not addressable, like a field
+// of a struct passed by value, can't be encoded; use a `*Dictionary` instead.
+//
+// Struct fields are encoded using the name in their `bencode` tag, or the field
+// name if the tag has no name. The `omitempty` option omits the field if it has
+// an empty value, and a `bencode:"-"` tag always omits it.
+//
+// Bencode has no null value, so nil pointers and nil interfaces are omitted
+// when they appear as dictionary values, and are an error anywhere else.
+// Values that contain themselves, like a struct with a pointer to itself, are
+// also an error.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	err := NewEncoder(buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// isNil reports whether `v` is a nil pointer or interface, an interface
+// holding a nil pointer, or is not a valid value at all.
+func isNil(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		return v.IsNil()
+	case reflect.Interface:
+		return v.IsNil() || isNil(v.Elem())
+	}
+
+	return false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// Encode writes the bencode encoding of `v`.
+//
+// See `Marshal` for details on how Go values are mapped. If an error is
+// returned, part of the value may have already been written, so the encoder
+// returns the same error from every following call.
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+
+		return e.err
+	}
+
+	return nil
+}
+
+// startDetectingCyclesAfter is the amount of nested pointers, maps and slices
+// after which `Encode` starts checking for cycles, which is expensive.
+const startDetectingCyclesAfter = 1000
+
+// cycleKey identifies a pointer, map or slice while looking for cycles.
+type cycleKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func (e *Encoder) encode(v reflect.Value) error {
+	if isNil(v) {
+		t := reflect.Type(nil)
+		if v.IsValid() {
+			t = v.Type()
+		}
+
+		return &ErrUnsupportedValue{
+			Type:   t,
+			Reason: "nil values can only be omitted from dictionaries",
+		}
+	}
+
+	// Interfaces are unwrapped first, so the values they hold are checked
+	// for cycles.
+	if v.Kind() == reflect.Interface {
+		return e.encode(v.Elem())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		e.ptrLevel++
+		defer func() {
+			e.ptrLevel--
+		}()
+
+		if e.ptrLevel > startDetectingCyclesAfter {
+			key := cycleKey{
+				ptr: v.Pointer(),
+				typ: v.Type(),
+			}
+			if v.Kind() == reflect.Slice {
+				key.len = v.Len()
+			}
+
+			if _, ok := e.ptrSeen[key]; ok {
+				return &ErrUnsupportedValue{
+					Type:   v.Type(),
+					Reason: "encountered a cycle",
+				}
+			}
+
+			if e.ptrSeen == nil {
+				e.ptrSeen = map[cycleKey]struct{}{}
+			}
+
+			e.ptrSeen[key] = struct{}{}
+			defer delete(e.ptrSeen, key)
+		}
+	}
+
+	// A `Dictionary` is only usable through a pointer, because it holds a
+	// mutex.
+	if v.Type() == dictionaryType {
+		if !v.CanAddr() {
+			return &ErrUnsupportedType{
+				Type: v.Type(),
+			}
+		}
+
+		v = v.Addr()
+	}
+
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		v = v.Addr()
+	}
+
+	if v.Type().Implements(marshalerType) && v.CanInterface() {
+		return e.encodeMarshaler(v)
+	}
+
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case Integer:
+			return e.Integer(int64(value))
+		case BigInteger:
+			return e.BigInteger(value.Int)
+		case *big.Int:
+			return e.BigInteger(value)
+		case big.Int:
+			return e.BigInteger(&value)
+		case String:
+			return e.String(value)
+		case List:
+			// `v` may be an interface holding the list.
+			return e.encodeList(reflect.ValueOf(value))
+		case *Dictionary:
+			if value == nil {
+				return &ErrUnsupportedValue{
+					Type:   v.Type(),
+					Reason: "nil values can only be omitted from dictionaries",
+				}
+			}
+
+			return e.encodeDictionary(value)
+		case Value:
+			return e.writeRaw(value.Bencode())
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return e.Integer(1)
+		}
+
+		return e.Integer(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.Integer(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.writeInteger(strconv.FormatUint(v.Uint(), 10))
+	case reflect.String:
+		return e.String([]byte(v.String()))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.String(v.Bytes())
+		}
+
+		return e.encodeList(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+
+			return e.String(b)
+		}
+
+		return e.encodeList(v)
+	case reflect.Map:
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return &ErrUnsupportedType{
+			Type: v.Type(),
+		}
+	}
+}
+
+func (e *Encoder) encodeMarshaler(v reflect.Value) error {
+	raw, err := v.Interface().(Marshaler).MarshalBencode()
+	if err == nil {
+		// `RawMessage` is written verbatim, even if it was taken from
+		// input that is not in canonical form.
+		isRaw := v.Type() == rawMessageType || v.Type() == reflect.PtrTo(rawMessageType)
+
+		err = validateValue(raw, !isRaw)
+	}
+	if err != nil {
+		return &ErrMarshaler{
+			Type: v.Type(),
+			Err:  err,
+		}
+	}
+
+	return e.writeRaw(raw)
+}
+
+// validateValue returns an error unless `raw` contains exactly one complete
+// bencode value, which must also be in canonical form if `isStrict` is true.
+func validateValue(raw []byte, isStrict bool) error {
+	options := DefaultDecoderOptions
+	options.Strict = isStrict
+	options.MaxIntegerLength = len(raw)
+	options.MaxStringLength = int64(len(raw))
+	options.MaxDepth = 0
+	options.BigIntegers = BigIntegersOnOverflow
+
+	d := NewDecoderWithOptions(bytes.NewReader(raw), options)
+
+	token, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	if err := d.skip(token); err != nil {
+		return err
+	}
+
+	if d.offset < int64(len(raw)) {
+		return &ErrTrailingData{
+			Offset: d.offset,
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeList(v reflect.Value) error {
+	if err := e.StartList(); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return e.End()
+}
+
+func (e *Encoder) encodeDictionary(d *Dictionary) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if err := e.StartDict(); err != nil {
+		return err
+	}
+
+	var err error
+
+	d.forEach(func(di *dictionaryItem) bool {
+		value := reflect.ValueOf(di.Value)
+		if isNil(value) {
+			return true
+		}
+
+		if err = e.String(di.Key); err != nil {
+			return false
+		}
+
+		err = e.encode(value)
+
+		return err == nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return e.End()
+}
+
+func (e *Encoder) encodeMap(v reflect.Value) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return &ErrUnsupportedType{
+			Type: v.Type(),
+		}
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	if err := e.StartDict(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		value := v.MapIndex(key)
+		if isNil(value) {
+			continue
+		}
+
+		if err := e.String([]byte(key.String())); err != nil {
+			return err
+		}
+
+		if err := e.encode(value); err != nil {
+			return err
+		}
+	}
+
+	return e.End()
+}
+
+func (e *Encoder) encodeStruct(v reflect.Value) error {
+	fields := cachedTypeFields(v.Type())
+
+	if err := e.StartDict(); err != nil {
+		return err
+	}
+
+	for _, f := range fields.list {
+		fv, ok := fieldByIndexNoAlloc(v, f.index)
+		if !ok || isNil(fv) {
+			continue
+		}
+
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		if err := e.String([]byte(f.name)); err != nil {
+			return err
+		}
+
+		if err := e.encode(fv); err != nil {
+			return err
+		}
+	}
+
+	return e.End()
+}
+
+// fieldByIndexNoAlloc is like `reflect.Value.FieldByIndex`, but reports
+// whether a nil embedded pointer was found along the way instead of
+// panicking.
+func fieldByIndexNoAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
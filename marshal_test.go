@@ -0,0 +1,437 @@
+package bencode_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+type marshalFile struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+	MD5Sum string   `bencode:"md5sum,omitempty"`
+}
+
+type marshalInfo struct {
+	Name        string        `bencode:"name"`
+	PieceLength int           `bencode:"piece length"`
+	Pieces      [2]byte       `bencode:"pieces"`
+	Private     bool          `bencode:"private,omitempty"`
+	Files       []marshalFile `bencode:"files"`
+}
+
+type marshalTorrent struct {
+	Announce string       `bencode:"announce"`
+	Info     *marshalInfo `bencode:"info"`
+	Ignored  string       `bencode:"-"`
+	Comment  string
+}
+
+func TestMarshal(t *testing.T) {
+	n := 5
+
+	testCases := []struct {
+		Value           interface{}
+		ExpectedBencode string
+	}{
+		{Value: 0, ExpectedBencode: "i0e"},
+		{Value: int8(-5), ExpectedBencode: "i-5e"},
+		{Value: uint64(18446744073709551615), ExpectedBencode: "i18446744073709551615e"},
+		{Value: true, ExpectedBencode: "i1e"},
+		{Value: false, ExpectedBencode: "i0e"},
+		{Value: &n, ExpectedBencode: "i5e"},
+		{Value: "spam", ExpectedBencode: "4:spam"},
+		{Value: []byte("spam"), ExpectedBencode: "4:spam"},
+		{Value: []byte(nil), ExpectedBencode: "0:"},
+		{Value: []interface{}{1, "a"}, ExpectedBencode: "li1e1:ae"},
+		{Value: []int{}, ExpectedBencode: "le"},
+		{
+			Value: map[string]interface{}{
+				"foo":  1,
+				"bar":  "x",
+				"\xff": 2,
+				"nil":  nil,
+			},
+			ExpectedBencode: "d3:bar1:x3:fooi1e1:\xffi2ee",
+		},
+		{
+			Value: bencode.List{
+				bencode.Integer(1),
+				bencode.String("a"),
+			},
+			ExpectedBencode: "li1e1:ae",
+		},
+		{
+			Value: marshalTorrent{
+				Announce: "http://t/",
+				Ignored:  "x",
+				Info: &marshalInfo{
+					Name:        "test",
+					PieceLength: 16,
+					Pieces:      [2]byte{0, 1},
+					Files: []marshalFile{
+						{
+							Length: 3,
+							Path:   []string{"a", "b"},
+						},
+					},
+				},
+			},
+			ExpectedBencode: "d7:Comment0:8:announce9:http://t/4:infod5:filesld6:lengthi3e4:pathl1:a1:beee4:name4:test12:piece lengthi16e6:pieces2:\x00\x01ee",
+		},
+	}
+
+	for i, tc := range testCases {
+		got, err := bencode.Marshal(tc.Value)
+		if err != nil {
+			t.Errorf("testCases[%d]: Marshal(%#v) returned error: %s", i, tc.Value, err)
+
+			continue
+		}
+
+		if got, expected := string(got), tc.ExpectedBencode; got != expected {
+			t.Errorf("testCases[%d]: Marshal(%#v) = %#v; expected %#v", i, tc.Value, got, expected)
+		}
+	}
+}
+
+func TestMarshal_KeyOrderMatchesDictionary(t *testing.T) {
+	keys := []string{"b", "a", "\xff", "\x00", "ab", ""}
+
+	m := map[string]bencode.Value{}
+	for _, key := range keys {
+		m[key] = bencode.Integer(len(key))
+	}
+
+	got, err := bencode.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := string(got), string(makeDictionary(m).Bencode()); got != expected {
+		t.Errorf("Marshal(%#v) = %#v; expected %#v", m, got, expected)
+	}
+}
+
+func TestMarshal_NilDictionary(t *testing.T) {
+	nilDictionary := (*bencode.Dictionary)(nil)
+
+	omitted := []struct {
+		Value           interface{}
+		ExpectedBencode string
+	}{
+		{
+			Value: map[string]bencode.Value{
+				"a": nilDictionary,
+				"b": bencode.Integer(1),
+			},
+			ExpectedBencode: "d1:bi1ee",
+		},
+		{
+			Value: makeDictionary(map[string]bencode.Value{
+				"a": nilDictionary,
+				"b": bencode.Integer(1),
+			}),
+			ExpectedBencode: "d1:bi1ee",
+		},
+		{
+			Value: struct {
+				A bencode.Value `bencode:"a"`
+			}{
+				A: nilDictionary,
+			},
+			ExpectedBencode: "de",
+		},
+	}
+
+	for i, tc := range omitted {
+		got, err := bencode.Marshal(tc.Value)
+		if err != nil {
+			t.Errorf("omitted[%d]: Marshal(%#v) returned error: %s", i, tc.Value, err)
+
+			continue
+		}
+
+		if string(got) != tc.ExpectedBencode {
+			t.Errorf("omitted[%d]: Marshal(%#v) = %#v; expected %#v", i, tc.Value, string(got), tc.ExpectedBencode)
+		}
+	}
+
+	rejected := []interface{}{
+		nilDictionary,
+		bencode.List{nilDictionary},
+		[]interface{}{nilDictionary},
+	}
+
+	for i, v := range rejected {
+		_, err := bencode.Marshal(v)
+
+		var valueErr *bencode.ErrUnsupportedValue
+		if !errors.As(err, &valueErr) {
+			t.Errorf("rejected[%d]: Marshal(%#v) = %#v; expected *bencode.ErrUnsupportedValue", i, v, err)
+		}
+	}
+}
+
+func TestMarshal_DictionaryValue(t *testing.T) {
+	var value struct {
+		D bencode.Dictionary `bencode:"d"`
+	}
+
+	input := "d1:dd1:ai1eee"
+
+	if err := bencode.Unmarshal([]byte(input), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := bencode.Marshal(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != input {
+		t.Errorf("Marshal(&value) = %#v; expected %#v", string(got), input)
+	}
+
+	// Map values are not addressable.
+	m := map[string]bencode.Dictionary{"d": {}}
+
+	var typeErr *bencode.ErrUnsupportedType
+	if _, err := bencode.Marshal(m); !errors.As(err, &typeErr) {
+		t.Errorf("Marshal(%#v) = %#v; expected *bencode.ErrUnsupportedType", m, err)
+	}
+}
+
+func TestMarshal_NilValueMessage(t *testing.T) {
+	testCases := []struct {
+		Value           interface{}
+		ExpectedMessage string
+	}{
+		{
+			Value:           nil,
+			ExpectedMessage: "unsupported nil value: nil values can only be omitted from dictionaries",
+		},
+		{
+			Value:           bencode.List{nil},
+			ExpectedMessage: "unsupported value of type bencode.Value: nil values can only be omitted from dictionaries",
+		},
+		{
+			Value:           (*int)(nil),
+			ExpectedMessage: "unsupported value of type *int: nil values can only be omitted from dictionaries",
+		},
+	}
+
+	for i, tc := range testCases {
+		_, err := bencode.Marshal(tc.Value)
+		if err == nil {
+			t.Errorf("testCases[%d]: Marshal(%#v) returned nil error", i, tc.Value)
+
+			continue
+		}
+
+		if got, expected := err.Error(), tc.ExpectedMessage; got != expected {
+			t.Errorf("testCases[%d]: err.Error() = %#v; expected %#v", i, got, expected)
+		}
+	}
+}
+
+type marshalNode struct {
+	Next *marshalNode `bencode:"next"`
+}
+
+func TestMarshal_Cycle(t *testing.T) {
+	node := &marshalNode{}
+	node.Next = node
+
+	m := map[string]interface{}{}
+	m["m"] = m
+
+	l := bencode.List{nil}
+	l[0] = l
+
+	d := bencode.NewDictionary()
+	d.Set(bencode.String("d"), d)
+
+	testCases := []interface{}{
+		node,
+		m,
+		l,
+		d,
+	}
+
+	for i, v := range testCases {
+		_, err := bencode.Marshal(v)
+
+		var valueErr *bencode.ErrUnsupportedValue
+		if !errors.As(err, &valueErr) {
+			t.Errorf("testCases[%d]: Marshal() = %#v; expected *bencode.ErrUnsupportedValue", i, err)
+		}
+	}
+
+	// Deep values without cycles are still encoded.
+	deep := &marshalNode{}
+	for i := 0; i < 2*1000; i++ {
+		deep = &marshalNode{Next: deep}
+	}
+
+	got, err := bencode.Marshal(deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := strings.Repeat("d4:next", 2*1000) + "de" + strings.Repeat("e", 2*1000); string(got) != expected {
+		t.Errorf("Marshal(deep) returned %d bytes; expected %d", len(got), len(expected))
+	}
+}
+
+func TestMarshal_Errors(t *testing.T) {
+	testCases := []interface{}{
+		nil,
+		(*int)(nil),
+		[]interface{}{nil},
+		1.5,
+		map[int]int{1: 1},
+		make(chan int),
+	}
+
+	for i, v := range testCases {
+		_, err := bencode.Marshal(v)
+
+		var (
+			typeErr  *bencode.ErrUnsupportedType
+			valueErr *bencode.ErrUnsupportedValue
+		)
+		if !errors.As(err, &typeErr) && !errors.As(err, &valueErr) {
+			t.Errorf("testCases[%d]: Marshal(%#v) = %#v; expected unsupported type or value error", i, v, err)
+		}
+	}
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	original := marshalTorrent{
+		Announce: "udp://tracker/",
+		Comment:  "comment",
+		Info: &marshalInfo{
+			Name:        "dir",
+			PieceLength: 1 << 18,
+			Pieces:      [2]byte{0xff, 0xfe},
+			Private:     true,
+			Files: []marshalFile{
+				{Length: 1, Path: []string{"a"}, MD5Sum: "x"},
+				{Length: 2, Path: []string{"b", "c"}},
+			},
+		},
+	}
+
+	raw, err := bencode.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded marshalTorrent
+	if err := bencode.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := bencode.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := string(again), string(raw); got != expected {
+		t.Errorf("Marshal(Unmarshal(Marshal(v))) = %#v; expected %#v", got, expected)
+	}
+}
+
+type marshalPeer struct {
+	IP   [4]byte
+	Port uint16
+}
+
+func (p marshalPeer) MarshalBencode() ([]byte, error) {
+	compact := []byte{p.IP[0], p.IP[1], p.IP[2], p.IP[3], byte(p.Port >> 8), byte(p.Port)}
+
+	return bencode.String(compact).Bencode(), nil
+}
+
+type marshalPointerReceiver struct {
+	N int
+}
+
+func (p *marshalPointerReceiver) MarshalBencode() ([]byte, error) {
+	return bencode.Integer(p.N * 2).Bencode(), nil
+}
+
+type marshalInvalid struct {
+	Raw string
+}
+
+func (m marshalInvalid) MarshalBencode() ([]byte, error) {
+	return []byte(m.Raw), nil
+}
+
+func TestMarshal_Marshaler(t *testing.T) {
+	value := struct {
+		Peer    marshalPeer             `bencode:"peer"`
+		Doubled *marshalPointerReceiver `bencode:"doubled"`
+	}{
+		Peer: marshalPeer{
+			IP:   [4]byte{127, 0, 0, 1},
+			Port: 6881,
+		},
+		Doubled: &marshalPointerReceiver{N: 21},
+	}
+
+	got, err := bencode.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := string(got), "d7:doubledi42e4:peer6:\x7f\x00\x00\x01\x1a\xe1e"; got != expected {
+		t.Errorf("Marshal(%#v) = %#v; expected %#v", value, got, expected)
+	}
+}
+
+func TestMarshal_MarshalerValidOutput(t *testing.T) {
+	testCases := []string{
+		"i99999999999999999999e",
+		"i-99999999999999999999e",
+		strings.Repeat("l", 1000) + strings.Repeat("e", 1000),
+	}
+
+	for i, raw := range testCases {
+		got, err := bencode.Marshal(bencode.RawMessage(raw))
+		if err != nil {
+			t.Errorf("testCases[%d]: Marshal(RawMessage(%#v)) returned error: %s", i, raw, err)
+
+			continue
+		}
+
+		if string(got) != raw {
+			t.Errorf("testCases[%d]: Marshal(RawMessage(%#v)) = %#v; expected %#v", i, raw, string(got), raw)
+		}
+	}
+}
+
+func TestMarshal_MarshalerInvalidOutput(t *testing.T) {
+	testCases := []string{
+		"",
+		"i1ei2e",
+		"l",
+		"e",
+		"3:ab",
+		"d1:bi1e1:ai2ee",
+		"d1:ai1e1:ai2ee",
+	}
+
+	for i, raw := range testCases {
+		_, err := bencode.Marshal(marshalInvalid{Raw: raw})
+
+		var marshalerErr *bencode.ErrMarshaler
+		if !errors.As(err, &marshalerErr) {
+			t.Errorf("testCases[%d]: Marshal(%#v) = %#v; expected *bencode.ErrMarshaler", i, raw, err)
+		}
+	}
+}
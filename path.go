@@ -0,0 +1,39 @@
+package bencode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Path identifies a value nested inside dictionaries and lists.
+//
+// Each element is either a dictionary key, or the index of a list item in
+// decimal.
+type Path []string
+
+// String formats the path as a JSON pointer (RFC 6901), e.g.
+// `/info/files/3/path`. The empty path is formatted as an empty string.
+func (p Path) String() string {
+	var sb strings.Builder
+
+	for _, element := range p {
+		sb.WriteByte('/')
+
+		element = strings.ReplaceAll(element, "~", "~0")
+		element = strings.ReplaceAll(element, "/", "~1")
+
+		sb.WriteString(element)
+	}
+
+	return sb.String()
+}
+
+// inPath formats `p` to be appended to an error message, or returns an empty
+// string if `p` is empty.
+func inPath(p Path) string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" in %q", p.String())
+}
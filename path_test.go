@@ -0,0 +1,33 @@
+package bencode_test
+
+import (
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+func TestPath_String(t *testing.T) {
+	testCases := []struct {
+		Value          bencode.Path
+		ExpectedString string
+	}{
+		{
+			Value:          nil,
+			ExpectedString: "",
+		},
+		{
+			Value:          bencode.Path{"info", "files", "3", "path"},
+			ExpectedString: "/info/files/3/path",
+		},
+		{
+			Value:          bencode.Path{"a/b", "~c", ""},
+			ExpectedString: "/a~1b/~0c/",
+		},
+	}
+
+	for i, tc := range testCases {
+		if got, expected := tc.Value.String(), tc.ExpectedString; got != expected {
+			t.Errorf("testCases[%d].Value.String() = %#v; expected %#v", i, got, expected)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package bencode
+
+import (
+	"errors"
+)
+
+var (
+	_ Marshaler   = RawMessage(nil)
+	_ Unmarshaler = (*RawMessage)(nil)
+)
+
+// RawMessage is a raw encoded bencode value.
+//
+// When decoding, it holds the exact bytes of the value as they appeared in the
+// input, which makes it possible to hash them (for example, to compute the
+// info hash of a torrent) or to delay decoding them. When encoding, its
+// contents are written verbatim. They must be exactly one complete value, but
+// unlike the output of other `Marshaler` types, they don't need to be in
+// canonical form, so values read from non-canonical input can be written
+// back unchanged.
+type RawMessage []byte
+
+// MarshalBencode returns `m` as the bencode encoding of `m`.
+func (m RawMessage) MarshalBencode() ([]byte, error) {
+	if len(m) == 0 {
+		return nil, errors.New("empty RawMessage")
+	}
+
+	return m, nil
+}
+
+// UnmarshalBencode sets `*m` to a copy of `data`.
+func (m *RawMessage) UnmarshalBencode(data []byte) error {
+	if m == nil {
+		return errors.New("UnmarshalBencode on nil pointer")
+	}
+
+	*m = append((*m)[0:0], data...)
+
+	return nil
+}
+
+// DecodeRaw reads the next complete bencode value, and returns its bytes
+// exactly as they appeared in the input.
+func (d *Decoder) DecodeRaw() ([]byte, error) {
+	var (
+		err   error
+		token Token
+	)
+
+	token, err = d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+
+	raw, err = d.rawValue(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
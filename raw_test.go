@@ -0,0 +1,99 @@
+package bencode_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+func TestDecoder_DecodeRaw(t *testing.T) {
+	input := "d1:ai1ee3:fooli1el1:xeei-5e"
+
+	d := bencode.NewDecoder(bytes.NewBuffer([]byte(input)))
+
+	for i, want := range []string{"d1:ai1ee", "3:foo", "li1el1:xee", "i-5e"} {
+		raw, err := d.DecodeRaw()
+		if err != nil {
+			t.Fatalf("values[%d]: d.DecodeRaw() returned error: %s", i, err)
+		}
+
+		if got := string(raw); got != want {
+			t.Errorf("values[%d]: d.DecodeRaw() = %#v; want %#v", i, got, want)
+		}
+	}
+}
+
+func TestRawMessage_Unmarshal(t *testing.T) {
+	// The keys of the `info` dictionary are not sorted, so decoding and
+	// encoding it again would produce different bytes.
+	rawInfo := "d4:name1:x6:lengthi1ee"
+	input := "d8:announce1:t4:info" + rawInfo + "e"
+
+	var torrent struct {
+		Announce string             `bencode:"announce"`
+		Info     bencode.RawMessage `bencode:"info"`
+	}
+
+	err := bencode.Unmarshal([]byte(input), &torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(torrent.Info), rawInfo; got != want {
+		t.Errorf("torrent.Info = %#v; want %#v", got, want)
+	}
+
+	if got, want := sha1.Sum(torrent.Info), sha1.Sum([]byte(rawInfo)); got != want {
+		t.Errorf("sha1.Sum(torrent.Info) = %x; want %x", got, want)
+	}
+}
+
+func TestRawMessage_Marshal(t *testing.T) {
+	value := map[string]interface{}{
+		"a": bencode.RawMessage("li1ei2ee"),
+		"b": 3,
+	}
+
+	got, err := bencode.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := string(got), "d1:ali1ei2ee1:bi3ee"; got != expected {
+		t.Errorf("Marshal(%#v) = %#v; expected %#v", value, got, expected)
+	}
+
+	if _, err := bencode.Marshal(bencode.RawMessage(nil)); err == nil {
+		t.Errorf("Marshal(RawMessage(nil)) returned nil error")
+	}
+}
+
+func TestRawMessage_MarshalNonCanonical(t *testing.T) {
+	input := "d4:infod1:bi1e1:ai2eee"
+
+	var torrent struct {
+		Info bencode.RawMessage `bencode:"info"`
+	}
+
+	if err := bencode.Unmarshal([]byte(input), &torrent); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := bencode.Marshal(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := string(got), input; got != expected {
+		t.Errorf("Marshal(%#v) = %#v; expected %#v", torrent, got, expected)
+	}
+
+	// The contents must still be exactly one complete value.
+	for _, raw := range []string{"i1ei2e", "d1:a"} {
+		if _, err := bencode.Marshal(bencode.RawMessage(raw)); err == nil {
+			t.Errorf("Marshal(RawMessage(%#v)) returned nil error", raw)
+		}
+	}
+}
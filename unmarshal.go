@@ -0,0 +1,605 @@
+package bencode
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// ErrInvalidUnmarshal is returned when the target passed to `Unmarshal` or
+// `Decoder.DecodeInto` is not a non-nil pointer.
+type ErrInvalidUnmarshal struct {
+	Type reflect.Type
+}
+
+func (e *ErrInvalidUnmarshal) Error() string {
+	if e.Type == nil {
+		return "cannot unmarshal into nil"
+	}
+
+	if e.Type.Kind() != reflect.Ptr {
+		return fmt.Sprintf("cannot unmarshal into non-pointer %s", e.Type)
+	}
+
+	return fmt.Sprintf("cannot unmarshal into nil %s", e.Type)
+}
+
+// ErrUnmarshalType is returned when a bencode value cannot be stored in a Go
+// value of the given type.
+type ErrUnmarshalType struct {
+	Offset int64
+	Value  string
+	Type   reflect.Type
+
+	// Path is the path of the bencode value.
+	Path Path
+}
+
+func (e *ErrUnmarshalType) Error() string {
+	return fmt.Sprintf("cannot unmarshal bencode %s at offset %d%s into Go value of type %s", e.Value, e.Offset, inPath(e.Path), e.Type)
+}
+
+// ErrIntegerOverflow is returned when a bencode integer is out of the range
+// of the Go integer type it's being stored in.
+type ErrIntegerOverflow struct {
+	Offset int64
+	Target reflect.Type
+
+	// Path is the path of the bencode integer.
+	Path Path
+}
+
+func (e *ErrIntegerOverflow) Error() string {
+	return fmt.Sprintf("integer at offset %d%s overflows Go value of type %s", e.Offset, inPath(e.Path), e.Target)
+}
+
+var (
+	_ error = (*ErrInvalidUnmarshal)(nil)
+	_ error = (*ErrUnmarshalType)(nil)
+	_ error = (*ErrIntegerOverflow)(nil)
+)
+
+var (
+	valueType      = reflect.TypeOf((*Value)(nil)).Elem()
+	dictionaryType = reflect.TypeOf((*Dictionary)(nil)).Elem()
+)
+
+// Unmarshaler is the interface implemented by types that can decode a bencode
+// representation of themselves.
+//
+// `UnmarshalBencode` receives the raw bytes of exactly one complete bencode
+// value. It must copy the data if it wishes to retain it after returning.
+type Unmarshaler interface {
+	UnmarshalBencode([]byte) error
+}
+
+// Unmarshal decodes the bencode value in `data` and stores the result in the
+// value pointed to by `v`. It returns `ErrTrailingData` if `data` contains
+// anything after the value.
+//
+// See `Decoder.DecodeInto` for details on how values are mapped.
+func Unmarshal(data []byte, v interface{}) error {
+	options := DefaultDecoderOptions
+	options.CopyStrings = true
+
+	d := NewBytesDecoderWithOptions(data, options)
+
+	if err := d.DecodeInto(v); err != nil {
+		return err
+	}
+
+	if d.offset < int64(len(data)) {
+		return &ErrTrailingData{
+			Offset: d.offset,
+		}
+	}
+
+	return nil
+}
+
+// DecodeInto reads the next bencode value and stores it in the value pointed
+// to by `v`.
+//
+// Integers can be stored in any Go integer type, in `bool` (only `0` and `1`
+// are accepted), and in `big.Int` and `BigInteger`. Strings can be stored in
+// `string`, `[]byte` and byte arrays. Lists can be stored in slices and
+// arrays. Dictionaries can be stored in structs, in maps with string keys,
+// and in `Dictionary`. Any value can be stored in an empty interface, using
+// the same types returned by `Decode`, and in a `Value`, using the same types
+// returned by `DecodeValue`.
+//
+// Struct fields are matched against dictionary keys by the name in their
+// `bencode` tag, or by the field name if the tag has no name. Fields with a
+// `bencode:"-"` tag are ignored. Dictionary keys that don't match any field are
+// skipped.
+//
+// Values that implement `Unmarshaler` receive the raw bytes of the value
+// instead. Pointers are allocated as necessary.
+func (d *Decoder) DecodeInto(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &ErrInvalidUnmarshal{
+			Type: reflect.TypeOf(v),
+		}
+	}
+
+	// Integers that don't fit in an int64 are parsed anyway, so they can be
+	// stored in a uint64 or a `big.Int`.
+	d.isUnmarshaling = true
+	defer func() {
+		d.isUnmarshaling = false
+	}()
+
+	var (
+		err   error
+		token Token
+	)
+
+	token, err = d.Token()
+	if err != nil {
+		return err
+	}
+
+	if err := d.unmarshal(token, rv.Elem()); err != nil {
+		return err
+	}
+
+	return d.finish()
+}
+
+// indirect walks down `v`, allocating pointers as needed, until it reaches a
+// non-pointer value. If a value along the way implements `Unmarshaler`, it
+// stops there and returns it.
+func indirect(v reflect.Value) (Unmarshaler, reflect.Value) {
+	// Start from the address of named types, so methods with pointer
+	// receivers are found.
+	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
+		v = v.Addr()
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(Unmarshaler); ok {
+				return u, reflect.Value{}
+			}
+		}
+
+		v = v.Elem()
+	}
+
+	return nil, v
+}
+
+func (d *Decoder) unmarshalTypeError(token Token, v reflect.Value) error {
+	err := &ErrUnmarshalType{
+		Offset: token.Offset(),
+		Value:  kindOfToken(token).String(),
+		Type:   v.Type(),
+		Path:   d.Path(),
+	}
+
+	// Consume the rest of the value, so the decoder is left at a known
+	// position.
+	if skipErr := d.skip(token); skipErr != nil {
+		return skipErr
+	}
+
+	return err
+}
+
+func (d *Decoder) unmarshal(token Token, v reflect.Value) error {
+	if t, ok := token.(*TokenStringReader); ok {
+		var err error
+
+		token, err = d.readString(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	u, v := indirect(v)
+	if u != nil {
+		raw, err := d.rawValue(token)
+		if err != nil {
+			return err
+		}
+
+		return u.UnmarshalBencode(raw)
+	}
+
+	switch v.Type() {
+	case valueType:
+		value, err := d.decodeValue(token)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(&value).Elem())
+
+		return nil
+	case dictionaryType:
+		if _, ok := token.(*TokenDictionaryStart); !ok {
+			return d.unmarshalTypeError(token, v)
+		}
+
+		return d.decodeDictionaryValue(v.Addr().Interface().(*Dictionary))
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() != 0 {
+			return d.unmarshalTypeError(token, v)
+		}
+
+		value, err := d.decodeAny(token)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(value))
+
+		return nil
+	}
+
+	switch parsedToken := token.(type) {
+	case *TokenInteger:
+		return d.unmarshalInteger(parsedToken, v)
+	case *TokenString:
+		return d.unmarshalString(parsedToken, v)
+	case *TokenSkippedString:
+		return d.unmarshalSkippedString(parsedToken, v)
+	case *TokenListStart:
+		return d.unmarshalList(parsedToken, v)
+	case *TokenDictionaryStart:
+		return d.unmarshalDictionary(parsedToken, v)
+	default:
+		return fmt.Errorf("unexpected token: %#v", parsedToken)
+	}
+}
+
+var (
+	bigIntType     = reflect.TypeOf(big.Int{})
+	bigIntegerType = reflect.TypeOf(BigInteger{})
+)
+
+func (d *Decoder) unmarshalInteger(token *TokenInteger, v reflect.Value) error {
+	switch v.Type() {
+	case bigIntType:
+		v.Addr().Interface().(*big.Int).Set(tokenBigInt(token))
+
+		return nil
+	case bigIntegerType:
+		v.Set(reflect.ValueOf(BigInteger{tokenBigInt(token)}))
+
+		return nil
+	}
+
+	n := token.Value
+	fitsInt64 := token.Big == nil || token.Big.IsInt64()
+	if token.Big != nil && fitsInt64 {
+		n = token.Big.Int64()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !fitsInt64 || v.OverflowInt(n) {
+			return d.integerOverflow(token, v)
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := uint64(n)
+		if !fitsInt64 {
+			if token.Big.Sign() < 0 || !token.Big.IsUint64() {
+				return d.integerOverflow(token, v)
+			}
+
+			u = token.Big.Uint64()
+		} else if n < 0 {
+			return d.integerOverflow(token, v)
+		}
+
+		if v.OverflowUint(u) {
+			return d.integerOverflow(token, v)
+		}
+
+		v.SetUint(u)
+	case reflect.Bool:
+		if !fitsInt64 || (n != 0 && n != 1) {
+			return d.unmarshalTypeError(token, v)
+		}
+
+		v.SetBool(n == 1)
+	default:
+		return d.unmarshalTypeError(token, v)
+	}
+
+	return nil
+}
+
+func (d *Decoder) integerOverflow(token *TokenInteger, v reflect.Value) error {
+	return &ErrIntegerOverflow{
+		Offset: token.Offset(),
+		Target: v.Type(),
+		Path:   d.Path(),
+	}
+}
+
+// tokenBigInt returns the value of `token` as a new `*big.Int`.
+func tokenBigInt(token *TokenInteger) *big.Int {
+	if token.Big != nil {
+		return new(big.Int).Set(token.Big)
+	}
+
+	return big.NewInt(token.Value)
+}
+
+func (d *Decoder) unmarshalString(token *TokenString, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(token.Value))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return d.unmarshalTypeError(token, v)
+		}
+
+		b := token.Value
+		if !d.ownsStrings() {
+			b = append([]byte(nil), b...)
+		}
+
+		v.SetBytes(b)
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 || v.Len() != len(token.Value) {
+			return d.unmarshalTypeError(token, v)
+		}
+
+		reflect.Copy(v, reflect.ValueOf(token.Value))
+	default:
+		return d.unmarshalTypeError(token, v)
+	}
+
+	return nil
+}
+
+var skippedStringType = reflect.TypeOf(TokenSkippedString{})
+
+// unmarshalSkippedString stores a skipped string in a `TokenSkippedString`.
+// For other string targets it returns an error, since the payload of the
+// string is no longer available.
+func (d *Decoder) unmarshalSkippedString(token *TokenSkippedString, v reflect.Value) error {
+	switch {
+	case v.Type() == skippedStringType:
+		v.Set(reflect.ValueOf(*token))
+
+		return nil
+	case v.Kind() == reflect.String:
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+	default:
+		return d.unmarshalTypeError(token, v)
+	}
+
+	return d.skippedStringError(token)
+}
+
+func (d *Decoder) unmarshalList(token *TokenListStart, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+
+		v.SetLen(0)
+	case reflect.Array:
+	default:
+		return d.unmarshalTypeError(token, v)
+	}
+
+	i := 0
+	for {
+		itemToken, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := itemToken.(*TokenEnd); ok {
+			break
+		}
+
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		} else if i >= v.Len() {
+			// Items that don't fit in the array are discarded.
+			if err := d.skip(itemToken); err != nil {
+				return err
+			}
+
+			i++
+
+			continue
+		}
+
+		if err := d.unmarshal(itemToken, v.Index(i)); err != nil {
+			return err
+		}
+
+		i++
+	}
+
+	if v.Kind() == reflect.Array {
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	}
+
+	return nil
+}
+
+func (d *Decoder) unmarshalDictionary(token *TokenDictionaryStart, v reflect.Value) error {
+	var fields *structFields
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields = cachedTypeFields(v.Type())
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return d.unmarshalTypeError(token, v)
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	default:
+		return d.unmarshalTypeError(token, v)
+	}
+
+	for {
+		var (
+			err error
+
+			keyToken   Token
+			valueToken Token
+		)
+
+		keyToken, err = d.Token()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := keyToken.(*TokenEnd); ok {
+			break
+		}
+
+		// `Token` only returns strings as dictionary keys, and returns an
+		// error instead of an end token where a value is expected.
+		key := string(keyToken.(*TokenString).Value)
+
+		valueToken, err = d.Token()
+		if err != nil {
+			return err
+		}
+
+		if fields != nil {
+			i, ok := fields.byName[key]
+			if !ok {
+				if err := d.skip(valueToken); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := d.unmarshal(valueToken, fieldByIndex(v, fields.list[i].index)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.unmarshal(valueToken, elem); err != nil {
+			return err
+		}
+
+		v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+	}
+
+	return nil
+}
+
+// fieldByIndex is like `reflect.Value.FieldByIndex`, but allocates nil
+// embedded pointers along the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// rawValue consumes the rest of the value that starts with `token`, and
+// returns its raw bytes.
+func (d *Decoder) rawValue(token Token) ([]byte, error) {
+	var raw []byte
+
+	depth := 0
+
+	for {
+		switch parsedToken := token.(type) {
+		case *TokenDictionaryStart, *TokenListStart:
+			depth++
+		case *TokenEnd:
+			depth--
+		case *TokenStringReader:
+			var err error
+
+			token, err = d.readString(parsedToken)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		case *TokenSkippedString:
+			return nil, d.skippedStringError(parsedToken)
+		}
+
+		raw = append(raw, token.Raw()...)
+
+		if depth <= 0 {
+			return raw, nil
+		}
+
+		var err error
+
+		token, err = d.Token()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// skippedStringError returns the error for a skipped string where its
+// contents are needed.
+func (d *Decoder) skippedStringError(token *TokenSkippedString) error {
+	return fmt.Errorf("string at offset %d was skipped: %w", token.Offset(), &ErrStringTooLong{
+		TokenOffset:     token.Offset(),
+		NextTokenOffset: token.Offset() + int64(len(token.Raw())) + token.Length,
+		Path:            d.Path(),
+	})
+}
+
+// skip consumes the rest of the value that starts with `token`.
+func (d *Decoder) skip(token Token) error {
+	depth := 0
+
+	for {
+		switch token.(type) {
+		case *TokenDictionaryStart, *TokenListStart:
+			depth++
+		case *TokenEnd:
+			depth--
+		}
+
+		if depth <= 0 {
+			return nil
+		}
+
+		var err error
+
+		token, err = d.Token()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package bencode_test
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+type unmarshalFile struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+}
+
+type unmarshalInfo struct {
+	Name        string          `bencode:"name"`
+	PieceLength int             `bencode:"piece length"`
+	Pieces      []byte          `bencode:"pieces"`
+	Private     bool            `bencode:"private,omitempty"`
+	Files       []unmarshalFile `bencode:"files"`
+}
+
+type unmarshalTorrent struct {
+	Announce string         `bencode:"announce"`
+	Info     *unmarshalInfo `bencode:"info"`
+	Ignored  string         `bencode:"-"`
+	Comment  string
+}
+
+func TestUnmarshal_Struct(t *testing.T) {
+	input := "d8:announce9:http://t/7:Comment2:hi7:Ignored1:x4:infod5:filesld6:lengthi3e4:pathl1:a1:beee4:name4:test12:piece lengthi16e6:pieces2:\x00\x017:privatei1eee"
+
+	var got unmarshalTorrent
+
+	err := bencode.Unmarshal([]byte(input), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := unmarshalTorrent{
+		Announce: "http://t/",
+		Comment:  "hi",
+		Info: &unmarshalInfo{
+			Name:        "test",
+			PieceLength: 16,
+			Pieces:      []byte{0, 1},
+			Private:     true,
+			Files: []unmarshalFile{
+				{
+					Length: 3,
+					Path:   []string{"a", "b"},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal(%#v) = %#v; want %#v", input, got, want)
+	}
+}
+
+func TestUnmarshal_Scalars(t *testing.T) {
+	var (
+		i   int
+		i8  int8
+		u16 uint16
+		b   bool
+		s   string
+		bs  []byte
+		arr [3]byte
+		ptr *int
+		v   interface{}
+	)
+
+	tests := []struct {
+		Input string
+		Value interface{}
+		Want  interface{}
+	}{
+		{Input: "i-42e", Value: &i, Want: -42},
+		{Input: "i127e", Value: &i8, Want: int8(127)},
+		{Input: "i65535e", Value: &u16, Want: uint16(65535)},
+		{Input: "i1e", Value: &b, Want: true},
+		{Input: "4:spam", Value: &s, Want: "spam"},
+		{Input: "4:spam", Value: &bs, Want: []byte("spam")},
+		{Input: "3:abc", Value: &arr, Want: [3]byte{'a', 'b', 'c'}},
+		{Input: "i7e", Value: &ptr, Want: func() *int { n := 7; return &n }()},
+		{Input: "li1e1:ae", Value: &v, Want: []interface{}{int64(1), []byte("a")}},
+	}
+
+	for i, tc := range tests {
+		err := bencode.Unmarshal([]byte(tc.Input), tc.Value)
+		if err != nil {
+			t.Errorf("tests[%d]: Unmarshal(%#v) returned error: %s", i, tc.Input, err)
+
+			continue
+		}
+
+		if got, want := reflect.ValueOf(tc.Value).Elem().Interface(), tc.Want; !reflect.DeepEqual(got, want) {
+			t.Errorf("tests[%d]: Unmarshal(%#v) = %#v; want %#v", i, tc.Input, got, want)
+		}
+	}
+}
+
+func TestUnmarshal_Map(t *testing.T) {
+	input := "d3:bari2e3:fooi1ee"
+
+	var got map[string]int
+
+	err := bencode.Unmarshal([]byte(input), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{
+		"foo": 1,
+		"bar": 2,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal(%#v) = %#v; want %#v", input, got, want)
+	}
+}
+
+func TestUnmarshal_Embedded(t *testing.T) {
+	type Base struct {
+		ID   int `bencode:"id"`
+		Name string
+	}
+
+	type Outer struct {
+		*Base
+		Name string
+	}
+
+	input := "d4:Name5:outer2:idi9ee"
+
+	var got Outer
+
+	err := bencode.Unmarshal([]byte(input), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Base == nil {
+		t.Fatalf("got.Base = nil; want non-nil")
+	}
+	if got, want := got.ID, 9; got != want {
+		t.Errorf("got.ID = %#v; want %#v", got, want)
+	}
+	if got, want := got.Name, "outer"; got != want {
+		t.Errorf("got.Name = %#v; want %#v", got, want)
+	}
+	if got, want := got.Base.Name, ""; got != want {
+		t.Errorf("got.Base.Name = %#v; want %#v", got, want)
+	}
+}
+
+func TestUnmarshal_TypeError(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Value  interface{}
+		Offset int64
+	}{
+		{Input: "4:spam", Value: new(int), Offset: 0},
+		{Input: "i2e", Value: new(bool), Offset: 0},
+		{Input: "d1:ai1ee", Value: new([]int), Offset: 0},
+		{Input: "li1e1:ae", Value: new([]int), Offset: 4},
+		{Input: "d1:ali1eee", Value: new(map[string]string), Offset: 4},
+	}
+
+	for i, tc := range tests {
+		err := bencode.Unmarshal([]byte(tc.Input), tc.Value)
+
+		var typeErr *bencode.ErrUnmarshalType
+		if !errors.As(err, &typeErr) {
+			t.Errorf("tests[%d]: Unmarshal(%#v) = %#v; want *bencode.ErrUnmarshalType", i, tc.Input, err)
+
+			continue
+		}
+
+		if got, want := typeErr.Offset, tc.Offset; got != want {
+			t.Errorf("tests[%d]: Unmarshal(%#v).Offset = %#v; want %#v", i, tc.Input, got, want)
+		}
+	}
+}
+
+func TestUnmarshal_InvalidTarget(t *testing.T) {
+	var s string
+
+	tests := []interface{}{
+		nil,
+		s,
+		(*string)(nil),
+	}
+
+	for i, v := range tests {
+		err := bencode.Unmarshal([]byte("1:a"), v)
+
+		var invalidErr *bencode.ErrInvalidUnmarshal
+		if !errors.As(err, &invalidErr) {
+			t.Errorf("tests[%d]: Unmarshal(..., %#v) = %#v; want *bencode.ErrInvalidUnmarshal", i, v, err)
+		}
+	}
+}
+
+func TestUnmarshal_TrailingData(t *testing.T) {
+	testCases := []struct {
+		Input          string
+		ExpectedOffset int64
+	}{
+		{
+			Input:          "i1ei2e",
+			ExpectedOffset: 3,
+		},
+		{
+			Input:          "i1ex",
+			ExpectedOffset: 3,
+		},
+		{
+			Input:          "li1eee",
+			ExpectedOffset: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		var v interface{}
+
+		err := bencode.Unmarshal([]byte(tc.Input), &v)
+
+		var trailingErr *bencode.ErrTrailingData
+		if !errors.As(err, &trailingErr) {
+			t.Errorf("Unmarshal(%#v) = %#v; want *bencode.ErrTrailingData", tc.Input, err)
+
+			continue
+		}
+
+		if got, want := trailingErr.Offset, tc.ExpectedOffset; got != want {
+			t.Errorf("Unmarshal(%#v): trailingErr.Offset = %#v; want %#v", tc.Input, got, want)
+		}
+	}
+}
+
+func TestDecoder_DecodeInto_Stream(t *testing.T) {
+	d := bencode.NewDecoder(bytes.NewBuffer([]byte("i1ei2e")))
+
+	for _, want := range []int{1, 2} {
+		var got int
+
+		if err := d.DecodeInto(&got); err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want {
+			t.Errorf("d.DecodeInto() = %#v; want %#v", got, want)
+		}
+	}
+}
+
+type unmarshalRawCapture struct {
+	Raw []byte
+}
+
+func (c *unmarshalRawCapture) UnmarshalBencode(raw []byte) error {
+	c.Raw = append([]byte(nil), raw...)
+
+	return nil
+}
+
+type unmarshalFailing struct{}
+
+var errUnmarshalFailing = errors.New("failing")
+
+func (unmarshalFailing) UnmarshalBencode(raw []byte) error {
+	return errUnmarshalFailing
+}
+
+func TestUnmarshal_Unmarshaler(t *testing.T) {
+	var got struct {
+		Info  unmarshalRawCapture  `bencode:"info"`
+		Ptr   *unmarshalRawCapture `bencode:"ptr"`
+		Value int                  `bencode:"value"`
+	}
+
+	input := "d4:infod1:ali1ei2ee1:b1:xe3:ptri-3e5:valuei4ee"
+
+	err := bencode.Unmarshal([]byte(input), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(got.Info.Raw), "d1:ali1ei2ee1:b1:xe"; got != want {
+		t.Errorf("got.Info.Raw = %#v; want %#v", got, want)
+	}
+	if got.Ptr == nil {
+		t.Fatalf("got.Ptr = nil; want non-nil")
+	}
+	if got, want := string(got.Ptr.Raw), "i-3e"; got != want {
+		t.Errorf("got.Ptr.Raw = %#v; want %#v", got, want)
+	}
+	if got, want := got.Value, 4; got != want {
+		t.Errorf("got.Value = %#v; want %#v", got, want)
+	}
+}
+
+func TestUnmarshal_UnmarshalerError(t *testing.T) {
+	var got unmarshalFailing
+
+	err := bencode.Unmarshal([]byte("i1e"), &got)
+	if !errors.Is(err, errUnmarshalFailing) {
+		t.Errorf("Unmarshal() = %#v; want %#v", err, errUnmarshalFailing)
+	}
+}
+
+func TestUnmarshal_TypeErrorPath(t *testing.T) {
+	input := "d4:infod5:filesld6:lengthi1eed6:length1:xeeee"
+
+	var torrent struct {
+		Info struct {
+			Files []struct {
+				Length int64 `bencode:"length"`
+			} `bencode:"files"`
+		} `bencode:"info"`
+	}
+
+	err := bencode.Unmarshal([]byte(input), &torrent)
+
+	var errUnmarshalType *bencode.ErrUnmarshalType
+	if !errors.As(err, &errUnmarshalType) {
+		t.Fatalf("Unmarshal() returned %#v; want *bencode.ErrUnmarshalType", err)
+	}
+
+	if got, want := errUnmarshalType.Path.String(), "/info/files/1/length"; got != want {
+		t.Errorf("errUnmarshalType.Path.String() = %#v; want %#v", got, want)
+	}
+}
+
+func TestUnmarshal_BigInteger(t *testing.T) {
+	input := "d1:ai99999999999999999999e1:bi-5e1:ci7ee"
+
+	var value struct {
+		A *big.Int           `bencode:"a"`
+		B big.Int            `bencode:"b"`
+		C bencode.BigInteger `bencode:"c"`
+	}
+
+	options := bencode.DefaultDecoderOptions
+	options.BigIntegers = bencode.BigIntegersOnOverflow
+
+	d := bencode.NewDecoderWithOptions(bytes.NewBuffer([]byte(input)), options)
+	if err := d.DecodeInto(&value); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := value.A.String(), "99999999999999999999"; got != want {
+		t.Errorf("value.A = %#v; want %#v", got, want)
+	}
+	if got, want := value.B.String(), "-5"; got != want {
+		t.Errorf("value.B = %#v; want %#v", got, want)
+	}
+	if got, want := value.C.String(), "7"; got != want {
+		t.Errorf("value.C = %#v; want %#v", got, want)
+	}
+
+	got, err := bencode.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(got), input; got != want {
+		t.Errorf("Marshal(value) = %#v; want %#v", got, want)
+	}
+}
+
+func TestUnmarshal_IntegerOverflow(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value interface{}
+	}{
+		{Input: "i256e", Value: new(uint8)},
+		{Input: "i-1e", Value: new(uint)},
+		{Input: "i128e", Value: new(int8)},
+		{Input: "i-129e", Value: new(int8)},
+		{Input: "i2147483648e", Value: new(int32)},
+		{Input: "i65536e", Value: new(uint16)},
+		{Input: "i9223372036854775808e", Value: new(int64)},
+		{Input: "i18446744073709551616e", Value: new(uint64)},
+		{Input: "i-18446744073709551615e", Value: new(uint64)},
+		{Input: "i9223372036854775808e", Value: new(interface{})},
+	}
+
+	for i, tc := range tests {
+		err := bencode.Unmarshal([]byte(tc.Input), tc.Value)
+
+		var overflowErr *bencode.ErrIntegerOverflow
+		if !errors.As(err, &overflowErr) {
+			t.Errorf("tests[%d]: Unmarshal(%#v) = %#v; want *bencode.ErrIntegerOverflow", i, tc.Input, err)
+
+			continue
+		}
+
+		if got, want := overflowErr.Offset, int64(0); got != want {
+			t.Errorf("tests[%d]: Unmarshal(%#v).Offset = %#v; want %#v", i, tc.Input, got, want)
+		}
+	}
+}
+
+func TestUnmarshal_SizedIntegers(t *testing.T) {
+	input := "d3:i32i-2147483648e3:i64i9223372036854775807e2:i8i-128e3:u64i18446744073709551615e2:u8i255ee"
+
+	var got struct {
+		I8  int8   `bencode:"i8"`
+		U8  uint8  `bencode:"u8"`
+		I32 int32  `bencode:"i32"`
+		U64 uint64 `bencode:"u64"`
+		I64 int64  `bencode:"i64"`
+	}
+
+	if err := bencode.Unmarshal([]byte(input), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := got.I8, int8(-128); got != want {
+		t.Errorf("got.I8 = %#v; want %#v", got, want)
+	}
+	if got, want := got.U8, uint8(255); got != want {
+		t.Errorf("got.U8 = %#v; want %#v", got, want)
+	}
+	if got, want := got.I32, int32(-2147483648); got != want {
+		t.Errorf("got.I32 = %#v; want %#v", got, want)
+	}
+	if got, want := got.U64, uint64(18446744073709551615); got != want {
+		t.Errorf("got.U64 = %#v; want %#v", got, want)
+	}
+	if got, want := got.I64, int64(9223372036854775807); got != want {
+		t.Errorf("got.I64 = %#v; want %#v", got, want)
+	}
+
+	encoded, err := bencode.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(encoded), input; got != want {
+		t.Errorf("Marshal(got) = %#v; want %#v", got, want)
+	}
+}
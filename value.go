@@ -1,7 +1,12 @@
 package bencode
 
+import (
+	"fmt"
+)
+
 var (
 	_ Value = (*Integer)(nil)
+	_ Value = (*BigInteger)(nil)
 	_ Value = (*String)(nil)
 	_ Value = (*List)(nil)
 	_ Value = (*Dictionary)(nil)
@@ -10,3 +15,123 @@ var (
 type Value interface {
 	Bencode() []byte
 }
+
+// DecodeValue reads the next bencode value and returns it as a `Value`:
+// `Integer` (or `BigInteger`, see `DecoderOptions.BigIntegers`), `String`,
+// `List` or `*Dictionary`.
+//
+// Encoding the result with `Bencode` produces the same bytes as the input,
+// as long as the input is canonical. Otherwise, dictionary keys are sorted,
+// and only the last value of a duplicate key is kept.
+func (d *Decoder) DecodeValue() (Value, error) {
+	token, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := d.decodeValue(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (d *Decoder) decodeValue(token Token) (Value, error) {
+	switch parsedToken := token.(type) {
+	case *TokenInteger:
+		n, err := d.decodeInteger(parsedToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if big, ok := n.(BigInteger); ok {
+			return big, nil
+		}
+
+		return Integer(n.(int64)), nil
+	case *TokenString:
+		s := parsedToken.Value
+		if !d.ownsStrings() {
+			s = append([]byte(nil), s...)
+		}
+
+		return String(s), nil
+	case *TokenStringReader:
+		token, err := d.readString(parsedToken)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.decodeValue(token)
+	case *TokenSkippedString:
+		return nil, d.skippedStringError(parsedToken)
+	case *TokenListStart:
+		return d.decodeListValue()
+	case *TokenDictionaryStart:
+		dict := NewDictionary()
+		if err := d.decodeDictionaryValue(dict); err != nil {
+			return nil, err
+		}
+
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("unexpected token: %#v", parsedToken)
+	}
+}
+
+func (d *Decoder) decodeListValue() (List, error) {
+	l := List{}
+
+	for {
+		itemToken, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := itemToken.(*TokenEnd); ok {
+			return l, nil
+		}
+
+		item, err := d.decodeValue(itemToken)
+		if err != nil {
+			return nil, err
+		}
+
+		l = append(l, item)
+	}
+}
+
+// decodeDictionaryValue adds the items of the dictionary that is being read to
+// `dict`.
+func (d *Decoder) decodeDictionaryValue(dict *Dictionary) error {
+	for {
+		keyToken, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := keyToken.(*TokenEnd); ok {
+			return nil
+		}
+
+		// `Token` only returns strings as dictionary keys.
+		key := String(append([]byte(nil), keyToken.(*TokenString).Value...))
+
+		valueToken, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		value, err := d.decodeValue(valueToken)
+		if err != nil {
+			return err
+		}
+
+		dict.Set(key, value)
+	}
+}
@@ -0,0 +1,86 @@
+package bencode_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/c032/go-bencode"
+)
+
+func TestDecoder_DecodeValue(t *testing.T) {
+	testCases := []string{
+		"i42e",
+		"4:spam",
+		"le",
+		"de",
+		"d3:bar4:spam3:bazli1ei0ei-1ee3:fooi42e5:lorem5:ipsume",
+		"d4:infod5:filesld6:lengthi1e4:pathl1:aeee4:name1:xee",
+	}
+
+	for i, input := range testCases {
+		for _, d := range []*bencode.Decoder{
+			bencode.NewDecoder(bytes.NewBuffer([]byte(input))),
+			bencode.NewBytesDecoder([]byte(input)),
+		} {
+			value, err := d.DecodeValue()
+			if err != nil {
+				t.Fatalf("testCases[%d]: d.DecodeValue() returned error: %s", i, err)
+			}
+
+			if got, want := string(value.Bencode()), input; got != want {
+				t.Errorf("testCases[%d]: value.Bencode() = %#v; want %#v", i, got, want)
+			}
+		}
+	}
+}
+
+func TestDecoder_DecodeValue_Modify(t *testing.T) {
+	input := []byte("d4:name1:x4:tagsl1:aee")
+
+	value, err := bencode.NewBytesDecoder(input).DecodeValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Modifying the input must not affect the decoded value.
+	input[8] = 'y'
+
+	dict := value.(*bencode.Dictionary)
+	dict.Set(bencode.String("comment"), bencode.String("hi"))
+
+	tags, _ := dict.Get(bencode.String("tags"))
+	dict.Set(bencode.String("tags"), append(tags.(bencode.List), bencode.String("b")))
+
+	if got, want := string(dict.Bencode()), "d7:comment2:hi4:name1:x4:tagsl1:a1:bee"; got != want {
+		t.Errorf("dict.Bencode() = %#v; want %#v", got, want)
+	}
+}
+
+func TestUnmarshal_Value(t *testing.T) {
+	input := "d4:infod4:name1:xe4:listli1ei2eee"
+
+	var got struct {
+		Info *bencode.Dictionary `bencode:"info"`
+		List bencode.Value       `bencode:"list"`
+	}
+
+	if err := bencode.Unmarshal([]byte(input), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(got.Info.Bencode()), "d4:name1:xe"; got != want {
+		t.Errorf("got.Info.Bencode() = %#v; want %#v", got, want)
+	}
+	if got, want := string(got.List.Bencode()), "li1ei2ee"; got != want {
+		t.Errorf("got.List.Bencode() = %#v; want %#v", got, want)
+	}
+
+	encoded, err := bencode.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(encoded), input; got != want {
+		t.Errorf("Marshal(got) = %#v; want %#v", got, want)
+	}
+}